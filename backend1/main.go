@@ -5,102 +5,147 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"go.opentelemetry.io/otel/propagation"
 	"io"
 	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/jaeger"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/trace"
+
+	"opentelemetry/internal/fraudpb"
+	"opentelemetry/internal/grpcx"
+	"opentelemetry/internal/httpx"
+	"opentelemetry/internal/telemetry"
 )
 
 const name = "payments"
 
-var tracer trace.Tracer
+// The fraud API is called over HTTP unless FRAUD_TRANSPORT is "grpc", in
+// which case the gRPC server at FRAUD_GRPC_ADDR is used.
+const defaultFraudGRPCAddr = "localhost:9002"
+
+var (
+	tracer      trace.Tracer
+	client      *http.Client
+	fraudClient fraudpb.FraudServiceClient
+)
 
 func main() {
 	fmt.Println("creating payments backend...")
 
 	l := log.New(os.Stdout, "", 0)
 
-	exp, err := newJaegerExporter()
+	t, err := telemetry.Setup(context.Background(), telemetry.Config{
+		ServiceName:       "payments",
+		Environment:       "staging",
+		BaggageAttributes: []string{"card_id", "payment_id", "tenant"},
+	})
 	if err != nil {
 		l.Fatal(err)
 	}
-
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
-		sdktrace.WithResource(newResource()),
-	)
 	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
+		if err := t.Shutdown(context.Background()); err != nil {
 			l.Fatal(err)
 		}
 	}()
 
-	otel.SetTracerProvider(tp)
+	tracer = t.TracerProvider.Tracer(name)
+	client = httpx.NewClient(t.TracerProvider, t.Propagator)
+	if err := newMetrics(t.MeterProvider); err != nil {
+		l.Fatal(err)
+	}
+
+	if os.Getenv("FRAUD_TRANSPORT") == "grpc" {
+		addr := os.Getenv("FRAUD_GRPC_ADDR")
+		if addr == "" {
+			addr = defaultFraudGRPCAddr
+		}
+		conn, err := grpcx.Dial(context.Background(), addr, t.TracerProvider, t.Propagator)
+		if err != nil {
+			l.Fatal(err)
+		}
+		defer conn.Close()
 
-	otel.SetTextMapPropagator(propagation.TraceContext{})
+		fraudClient = fraudpb.NewFraudServiceClient(conn)
+	}
 
-	tracer = tp.Tracer(name)
+	var mux http.ServeMux
+	mux.Handle("/api/payment", otelhttp.WithRouteTag("/api/payment", processPayment()))
 
-	http.HandleFunc("/api/payment", processPayment())
+	// Scrapes of /metrics are kept out of the traces and of the metrics
+	// themselves.
+	var root http.ServeMux
+	root.Handle("/metrics", t.Metrics)
+	root.Handle("/", httpx.NewHandler(&mux, t.TracerProvider, t.Propagator, httpx.WithMeterProvider(t.MeterProvider)))
 
-	if err := http.ListenAndServe("localhost:9000", nil); err != nil {
+	if err := http.ListenAndServe(":9000", &root); err != nil {
 		panic(err)
 	}
 }
 
-func processPayment() func(w http.ResponseWriter, r *http.Request) {
+func processPayment() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, span := tracer.Start(r.Context(), "HTTP POST /api/payment")
-		defer span.End()
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
 
 		var p struct {
-			Amount string `json:"amount"`
-			CardID string `json:"card_id"`
+			Amount   string `json:"amount"`
+			CardID   string `json:"card_id"`
+			Currency string `json:"currency"`
 		}
 
 		b, _ := io.ReadAll(r.Body)
 		err := json.Unmarshal(b, &p)
 		if err != nil {
-			span.RecordError(err)
-
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			httpx.Error(w, span, err, http.StatusBadRequest)
 
 			return
 		}
 
 		paymentID := rand.Int()
 
-		if err := fraudScoringCheck(ctx, p.CardID, p.Amount); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
+		tenant := r.Header.Get("X-Tenant-ID")
+		if tenant == "" {
+			tenant = "default"
+		}
+		ctx, err = withPaymentBaggage(ctx, p.CardID, fmt.Sprintf("%d", paymentID), tenant)
+		if err != nil {
+			httpx.Error(w, span, err, http.StatusBadRequest)
 
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		span.SetAttributes(
+			attribute.String("card_id", p.CardID),
+			attribute.String("payment_id", fmt.Sprintf("%d", paymentID)),
+			attribute.String("tenant", tenant),
+		)
+
+		if err := fraudScoringCheck(ctx, p.CardID, p.Amount); err != nil {
+			httpx.Error(w, span, err, http.StatusInternalServerError)
 
 			return
 		}
 
 		if err := save(ctx, fmt.Sprintf("%d", paymentID)); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			httpx.Error(w, span, err, http.StatusInternalServerError)
 
 			return
 		}
 
+		currency := currencyAttr(p.Currency)
+		paymentsCreated.Add(ctx, 1, currency)
+		if amount, err := strconv.ParseFloat(p.Amount, 64); err == nil {
+			paymentsAmount.Record(ctx, amount, currency)
+		}
+
 		// fmt.Println("payment id", paymentID, "created successfully")
 
 		w.Write([]byte(fmt.Sprintf(`{"id":"%d"}`, paymentID)))
@@ -111,33 +156,82 @@ func processPayment() func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// withPaymentBaggage puts the payment identifiers in the baggage of ctx, so
+// that the fraud and notification services can tag their spans with them.
+// Values are percent-encoded, since baggage only takes those characters
+// allowed on the wire, e.g. no spaces, and client input may have any. The
+// spans get them decoded again.
+func withPaymentBaggage(ctx context.Context, cardID, paymentID, tenant string) (context.Context, error) {
+	var members []baggage.Member
+	for _, kv := range [][2]string{
+		{"card_id", cardID},
+		{"payment_id", paymentID},
+		{"tenant", tenant},
+	} {
+		m, err := baggage.NewMember(kv[0], url.PathEscape(kv[1]))
+		if err != nil {
+			return ctx, fmt.Errorf("invalid %s: %w", kv[0], err)
+		}
+		members = append(members, m)
+	}
+
+	bag, err := baggage.New(members...)
+	if err != nil {
+		return ctx, err
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
 func fraudScoringCheck(ctx context.Context, cardID, amount string) error {
-	_, span := tracer.Start(ctx, "fraud-scoring-api")
+	ctx, span := tracer.Start(ctx, "fraud-scoring-api")
 	defer span.End()
 
-	req, _ := http.NewRequest("POST",
+	ctx, cancelFn := httpx.WithDefaultTimeout(ctx, 3*time.Second)
+	defer cancelFn()
+
+	if fraudClient != nil {
+		res, err := fraudClient.Score(ctx, &fraudpb.ScoreRequest{CardId: cardID, Amount: amount})
+		if err != nil {
+			return grpcx.CheckError(span, err)
+		}
+		span.SetAttributes(attribute.Bool("fraud.approved", res.Approved))
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
 		"http://localhost:9001/api/fraud",
 		strings.NewReader(fmt.Sprintf(`{"card_id":"%s", "amount":"%s"}`, cardID, amount)),
 	)
-
-	ctx, cancelFn := context.WithTimeout(ctx, 3*time.Second)
-	defer cancelFn()
-
-	req.WithContext(ctx)
+	if err != nil {
+		return err
+	}
 	req.Header.Set("content-type", "application/json")
 
-	_, err := http.DefaultClient.Do(req)
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
 
-	return err
+	return httpx.CheckResponse(span, res)
 }
 
 // save simulates a db call
 func save(ctx context.Context, paymentID string) error {
-	_, span := tracer.Start(ctx, "save-payment")
+	ctx, span := tracer.Start(ctx, "save-payment")
 	defer span.End()
 
 	s := rand.Intn(5)
-	time.Sleep(time.Duration(s) * time.Second)
+	select {
+	case <-time.After(time.Duration(s) * time.Second):
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		if httpx.IsDeadlineExceeded(ctx.Err()) {
+			httpx.SetDeadlineExceeded(span)
+		}
+		return ctx.Err()
+	}
 
 	const timeout int = 2
 	if s > timeout {
@@ -146,24 +240,3 @@ func save(ctx context.Context, paymentID string) error {
 
 	return nil
 }
-
-func newJaegerExporter() (sdktrace.SpanExporter, error) {
-	os.Setenv("OTEL_EXPORTER_JAEGER_ENDPOINT", "http://localhost:14268/api/traces")
-	os.Setenv("OTEL_EXPORTER_JAEGER_AGENT_PORT", "6831")
-
-	return jaeger.New(jaeger.WithAgentEndpoint())
-}
-
-// newResource returns a resource describing this application.
-func newResource() *resource.Resource {
-	r, _ := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String("payments"),
-			semconv.ServiceVersionKey.String("v0.1.0"),
-			attribute.String("environment", "staging"),
-		),
-	)
-	return r
-}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+// The payment metrics, served on /metrics with the RED ones:
+//
+//	payments_created_total{currency}  counter of payments saved
+//	payments_amount{currency}         histogram of the amount of those payments
+//
+// currency is one of currencies, or "other", so that it stays bounded
+// whatever clients send.
+var (
+	paymentsCreated syncint64.Counter
+	paymentsAmount  syncfloat64.Histogram
+)
+
+const defaultCurrency = "USD"
+
+var currencies = map[string]bool{"USD": true, "EUR": true, "GBP": true, "ARS": true, "BRL": true}
+
+func newMetrics(mp metric.MeterProvider) error {
+	meter := mp.Meter(name)
+
+	var err error
+	if paymentsCreated, err = meter.SyncInt64().Counter("payments.created",
+		instrument.WithDescription("Payments created, by currency")); err != nil {
+		return err
+	}
+	paymentsAmount, err = meter.SyncFloat64().Histogram("payments.amount",
+		instrument.WithDescription("Amount of the payments created, in their currency"))
+	return err
+}
+
+// currencyAttr returns the currency attribute of a payment in currency.
+func currencyAttr(currency string) attribute.KeyValue {
+	currency = strings.ToUpper(currency)
+	switch {
+	case currency == "":
+		currency = defaultCurrency
+	case !currencies[currency]:
+		currency = "other"
+	}
+	return attribute.String("currency", currency)
+}
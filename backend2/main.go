@@ -5,59 +5,91 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/jaeger"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"go.opentelemetry.io/otel/trace"
 	"io"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
+
+	"opentelemetry/internal/fraudpb"
+	"opentelemetry/internal/grpcx"
+	"opentelemetry/internal/httpx"
+	"opentelemetry/internal/queue"
+	"opentelemetry/internal/telemetry"
 )
 
 const name string = "fraud"
 
-var tracer trace.Tracer
+const grpcAddr = "localhost:9002"
+
+// Notifications are sent over HTTP unless NOTIFICATION_DELIVERY is "queue",
+// in which case they are published to the NOTIFICATION_QUEUE queue and
+// delivered by the notification service in the background. The default
+// queue is a directory both services find wherever they are started from.
+const notificationDestination = "notifications"
+
+var defaultNotificationQueue = "file://" + filepath.Join(os.TempDir(), "notifications")
+
+var (
+	tracer        trace.Tracer
+	client        *http.Client
+	notifications *queue.Producer
+)
 
 func main() {
 	fmt.Println("creating fraud backend...")
 
 	l := log.New(os.Stdout, "", 0)
 
-	exp, err := newJaegerExporter()
+	t, err := telemetry.Setup(context.Background(), telemetry.Config{
+		ServiceName:       "fraud",
+		Environment:       "staging",
+		BaggageAttributes: []string{"card_id", "payment_id", "tenant"},
+	})
 	if err != nil {
 		l.Fatal(err)
 	}
-
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
-		sdktrace.WithResource(newResource()),
-	)
 	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
+		if err := t.Shutdown(context.Background()); err != nil {
 			l.Fatal(err)
 		}
 	}()
 
-	otel.SetTracerProvider(tp)
+	tracer = t.TracerProvider.Tracer(name)
+	client = httpx.NewClient(t.TracerProvider, t.Propagator)
+	if err := newMetrics(t.MeterProvider); err != nil {
+		l.Fatal(err)
+	}
 
-	tracer = tp.Tracer(name)
+	if os.Getenv("NOTIFICATION_DELIVERY") == "queue" {
+		url := os.Getenv("NOTIFICATION_QUEUE")
+		if url == "" {
+			url = defaultNotificationQueue
+		}
+		q, err := queue.Open(url)
+		if err != nil {
+			l.Fatal(err)
+		}
+		defer q.Close()
 
-	http.HandleFunc("/api/fraud", func(w http.ResponseWriter, r *http.Request) {
+		notifications = queue.NewProducer(q, notificationDestination, t.TracerProvider, t.Propagator)
+	}
+
+	h := func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			get(w, r)
 			return
 		}
 
-		ctx, span := tracer.Start(r.Context(), "HTTP POST /api/fraud")
-		defer span.End()
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
 
 		var p struct {
 			Amount string `json:"amount"`
@@ -66,72 +98,110 @@ func main() {
 
 		b, err := io.ReadAll(r.Body)
 		if err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-
-			w.WriteHeader(http.StatusInternalServerError)
+			httpx.Error(w, span, err, http.StatusInternalServerError)
 
 			return
 		}
 
 		if err := json.Unmarshal(b, &p); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-
-			w.WriteHeader(http.StatusBadRequest)
+			httpx.Error(w, span, err, http.StatusBadRequest)
 
 			return
 		}
 
-		approved := score(ctx, p.CardID, p.Amount)
-
-		if err := save(ctx, p.CardID, p.Amount, approved); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-
-			w.WriteHeader(http.StatusInternalServerError)
+		if _, err := scorePayment(ctx, p.CardID, p.Amount); err != nil {
+			httpx.Error(w, span, err, http.StatusInternalServerError)
 			return
 		}
 
-		if err := sendNotification(ctx, p.CardID); err != nil {
-			fmt.Println("error sending notification", err.Error())
-
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+	// The same API is served over gRPC on grpcAddr.
+	lis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		l.Fatal(err)
+	}
+	s := grpcx.NewServer(t.TracerProvider, t.Propagator)
+	fraudpb.RegisterFraudServiceServer(s, fraudServer{})
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			l.Fatal(err)
 		}
+	}()
+	defer s.GracefulStop()
 
-		w.WriteHeader(http.StatusOK)
-		return
-	})
+	var mux http.ServeMux
+	mux.Handle("/api/fraud", otelhttp.WithRouteTag("/api/fraud", http.HandlerFunc(h)))
 
-	if err := http.ListenAndServe("localhost:9001", nil); err != nil {
+	var root http.ServeMux
+	root.Handle("/metrics", t.Metrics)
+	root.Handle("/", httpx.NewHandler(&mux, t.TracerProvider, t.Propagator, httpx.WithMeterProvider(t.MeterProvider)))
+
+	if err := http.ListenAndServe(":9001", &root); err != nil {
 		panic(err)
 	}
 }
 
 func get(w http.ResponseWriter, r *http.Request) {
-	_, span := tracer.Start(r.Context(), "HTTP GET /api/fraud")
-	defer span.End()
+	span := trace.SpanFromContext(r.Context())
 
 	var p struct {
 		CardID string `json:"card_id"`
 	}
 	b, _ := io.ReadAll(r.Body)
 	if err := json.Unmarshal(b, &p); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
-
-		w.WriteHeader(http.StatusBadRequest)
+		httpx.Error(w, span, err, http.StatusBadRequest)
 
 		return
 	}
 
+	w.Write([]byte(fmt.Sprintf(`{"status":"%s"}`, cardStatus(r.Context(), p.CardID))))
+}
+
+func cardStatus(ctx context.Context, cardID string) string {
 	time.Sleep(250 * time.Millisecond)
 
-	w.Write([]byte(fmt.Sprintf(`{"status":"active"}`)))
+	return "active"
+}
+
+// scorePayment decides whether the payment is approved, stores the decision
+// and notifies the card holder.
+func scorePayment(ctx context.Context, cardID, amount string) (bool, error) {
+	approved := score(ctx, cardID, amount)
+
+	if err := save(ctx, cardID, amount, approved); err != nil {
+		return false, err
+	}
+
+	notify := sendNotification
+	if notifications != nil {
+		notify = publishNotification
+	}
+	if err := notify(ctx, cardID); err != nil {
+		fmt.Println("error sending notification", err.Error())
+		return false, err
+	}
+
+	return approved, nil
+}
+
+// fraudServer serves the fraud API over gRPC.
+type fraudServer struct {
+	fraudpb.UnimplementedFraudServiceServer
+}
+
+func (fraudServer) Score(ctx context.Context, req *fraudpb.ScoreRequest) (*fraudpb.ScoreResponse, error) {
+	approved, err := scorePayment(ctx, req.CardId, req.Amount)
+	if err != nil {
+		return nil, grpcx.Error(err)
+	}
+	return &fraudpb.ScoreResponse{Approved: approved}, nil
+}
+
+func (fraudServer) GetCardStatus(ctx context.Context, req *fraudpb.GetCardStatusRequest) (*fraudpb.GetCardStatusResponse, error) {
+	return &fraudpb.GetCardStatusResponse{Status: cardStatus(ctx, req.CardId)}, nil
 }
 
 func score(ctx context.Context, cardID, amount string) bool {
@@ -140,27 +210,41 @@ func score(ctx context.Context, cardID, amount string) bool {
 
 	time.Sleep(800 * time.Millisecond)
 
-	return rand.Intn(2) == 1
+	approved := rand.Intn(2) == 1
+	fraudDecisions.Add(ctx, 1, decisionAttr(approved))
+	return approved
+}
+
+// publishNotification queues the notification instead of waiting for it to
+// be delivered.
+func publishNotification(ctx context.Context, cardID string) error {
+	return notifications.Publish(ctx, []byte(fmt.Sprintf(`{"card_id":"%s"}`, cardID)))
 }
 
 func sendNotification(ctx context.Context, cardID string) error {
-	_, span := tracer.Start(ctx, "notification-api")
+	ctx, span := tracer.Start(ctx, "notification-api")
 	defer span.End()
 
-	req, _ := http.NewRequest("POST",
+	ctx, cancelFn := httpx.WithDefaultTimeout(ctx, 3*time.Second)
+	defer cancelFn()
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
 		"http://localhost:9003/api/notification",
 		strings.NewReader(fmt.Sprintf(`{"card_id":"%s"}`, cardID)),
 	)
-
-	ctx, cancelFn := context.WithTimeout(ctx, 3*time.Second)
-	defer cancelFn()
-
-	req.WithContext(ctx)
+	if err != nil {
+		return err
+	}
 	req.Header.Set("content-type", "application/json")
 
-	_, err := http.DefaultClient.Do(req)
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
 
-	return err
+	return httpx.CheckResponse(span, res)
 }
 
 func save(ctx context.Context, cardID, amount string, approved bool) error {
@@ -171,7 +255,15 @@ func save(ctx context.Context, cardID, amount string, approved bool) error {
 	defer span.End()
 
 	s := rand.Intn(5)
-	time.Sleep(time.Duration(s) * time.Second)
+	select {
+	case <-time.After(time.Duration(s) * time.Second):
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		if httpx.IsDeadlineExceeded(ctx.Err()) {
+			httpx.SetDeadlineExceeded(span)
+		}
+		return ctx.Err()
+	}
 
 	const timeout int = 2
 	if s > timeout {
@@ -180,24 +272,3 @@ func save(ctx context.Context, cardID, amount string, approved bool) error {
 
 	return nil
 }
-
-func newJaegerExporter() (sdktrace.SpanExporter, error) {
-	os.Setenv("OTEL_EXPORTER_JAEGER_ENDPOINT", "http://localhost:14268/api/traces")
-	os.Setenv("OTEL_EXPORTER_JAEGER_AGENT_PORT", "6831")
-
-	return jaeger.New(jaeger.WithAgentEndpoint())
-}
-
-// newResource returns a resource describing this application.
-func newResource() *resource.Resource {
-	r, _ := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String("fraud"),
-			semconv.ServiceVersionKey.String("v0.1.0"),
-			attribute.String("environment", "staging"),
-		),
-	)
-	return r
-}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+// The fraud metrics, served on /metrics with the RED ones:
+//
+//	fraud_decisions_total{decision}  counter of scored payments, decision
+//	                                 being "approved" or "declined"
+var fraudDecisions syncint64.Counter
+
+func newMetrics(mp metric.MeterProvider) (err error) {
+	fraudDecisions, err = mp.Meter(name).SyncInt64().Counter("fraud.decisions",
+		instrument.WithDescription("Fraud decisions taken, approved or declined"))
+	return err
+}
+
+// decisionAttr returns the decision attribute of a score.
+func decisionAttr(approved bool) attribute.KeyValue {
+	if approved {
+		return attribute.String("decision", "approved")
+	}
+	return attribute.String("decision", "declined")
+}
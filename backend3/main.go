@@ -10,56 +10,99 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"opentelemetry/internal/fraudpb"
+	"opentelemetry/internal/grpcx"
+	"opentelemetry/internal/httpx"
+	"opentelemetry/internal/queue"
+	"opentelemetry/internal/telemetry"
 )
 
-const name string = "fraud"
+const name string = "notification"
+
+// With NOTIFICATION_DELIVERY set to "queue" notifications are also taken
+// from the NOTIFICATION_QUEUE queue the fraud service publishes to. Both
+// default to the same directory under os.TempDir.
+const notificationDestination = "notifications"
+
+var defaultNotificationQueue = "file://" + filepath.Join(os.TempDir(), "notifications")
 
-var tracer trace.Tracer
+// The fraud API is called over HTTP unless FRAUD_TRANSPORT is "grpc", in
+// which case the gRPC server at FRAUD_GRPC_ADDR is used.
+const defaultFraudGRPCAddr = "localhost:9002"
+
+var (
+	tracer      trace.Tracer
+	client      *http.Client
+	fraudClient fraudpb.FraudServiceClient
+)
 
 func main() {
 	// this backed uses SigNoz as observability & monitoring platform
 	l := log.New(os.Stdout, "", 0)
 
-	exp, err := otlptrace.New(
-		context.Background(),
-		otlptracegrpc.NewClient(
-			otlptracegrpc.WithInsecure(),
-			otlptracegrpc.WithEndpoint(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")),
-		),
-	)
+	fmt.Println("settings trace provider")
+	t, err := telemetry.Setup(context.Background(), telemetry.Config{
+		ServiceName:       "notification",
+		Environment:       "staging",
+		BaggageAttributes: []string{"card_id", "payment_id", "tenant"},
+		Exporter:          telemetry.ExporterOTLPGRPC,
+	})
 	if err != nil {
 		l.Fatal(err)
 	}
-
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
-		sdktrace.WithResource(newResource()),
-	)
 	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
+		if err := t.Shutdown(context.Background()); err != nil {
 			l.Fatal(err)
 		}
 	}()
 
-	fmt.Println("settings trace provider")
-	otel.SetTracerProvider(tp)
+	tracer = t.TracerProvider.Tracer(name)
+	client = httpx.NewClient(t.TracerProvider, t.Propagator)
+	if err := newMetrics(t.MeterProvider); err != nil {
+		l.Fatal(err)
+	}
 
-	tracer = tp.Tracer(name)
+	if os.Getenv("FRAUD_TRANSPORT") == "grpc" {
+		addr := os.Getenv("FRAUD_GRPC_ADDR")
+		if addr == "" {
+			addr = defaultFraudGRPCAddr
+		}
+		conn, err := grpcx.Dial(context.Background(), addr, t.TracerProvider, t.Propagator)
+		if err != nil {
+			l.Fatal(err)
+		}
+		defer conn.Close()
+
+		fraudClient = fraudpb.NewFraudServiceClient(conn)
+	}
 	fmt.Println("tracer set")
 
+	if os.Getenv("NOTIFICATION_DELIVERY") == "queue" {
+		url := os.Getenv("NOTIFICATION_QUEUE")
+		if url == "" {
+			url = defaultNotificationQueue
+		}
+		q, err := queue.Open(url)
+		if err != nil {
+			l.Fatal(err)
+		}
+		defer q.Close()
+
+		go func() {
+			if err := queue.Consume(context.Background(), q, notificationDestination, t.TracerProvider, t.Propagator, handleNotification); err != nil {
+				l.Println("consuming notifications:", err)
+			}
+		}()
+	}
+
 	h := func(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("handler")
 		ctx, span := tracer.Start(r.Context(),
@@ -68,8 +111,6 @@ func main() {
 		)
 		defer span.End()
 
-		labeler, _ := otelhttp.LabelerFromContext(ctx)
-
 		span.AddEvent("an-event")
 
 		var p struct {
@@ -78,49 +119,30 @@ func main() {
 
 		b, err := io.ReadAll(r.Body)
 		if err != nil {
-
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-			labeler.Add(attribute.Bool("error", true))
-
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-
+			httpx.Error(w, span, err, http.StatusInternalServerError)
 			return
 		}
 
 		if err := json.Unmarshal(b, &p); err != nil {
-
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-			labeler.Add(attribute.Bool("error", true))
-
-			http.Error(w, err.Error(), http.StatusBadRequest)
-
+			httpx.Error(w, span, err, http.StatusBadRequest)
 			return
 		}
 
 		if err := save(ctx, p.CardID); err != nil {
-
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
-			labeler.Add(attribute.Bool("error", true))
-
-			w.WriteHeader(http.StatusInternalServerError)
+			httpx.Error(w, span, err, http.StatusInternalServerError)
 			return
 		}
 
 		status, err := checkFraud(ctx, p.CardID)
-		if err != nil || status != "active" {
-
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "error")
-			labeler.Add(attribute.Bool("error", true))
-
-			w.WriteHeader(http.StatusInternalServerError)
+		if err != nil {
+			httpx.Error(w, span, err, http.StatusInternalServerError)
+			return
+		}
+		if status != "active" {
+			httpx.Error(w, span, fmt.Errorf("card status %q", status), http.StatusInternalServerError)
 			return
 		}
 
-		labeler.Add(attribute.Bool("error", false))
 		w.WriteHeader(http.StatusOK)
 		return
 	}
@@ -130,35 +152,72 @@ func main() {
 
 	fmt.Println("handler set")
 
-	if err := http.ListenAndServe(":9003", otelhttp.NewHandler(&mux,
-		"POST /api/notification",
-		otelhttp.WithMessageEvents(otelhttp.ReadEvents, otelhttp.WriteEvents)),
-	); err != nil {
+	var root http.ServeMux
+	root.Handle("/metrics", t.Metrics)
+	root.Handle("/", httpx.NewHandler(&mux, t.TracerProvider, t.Propagator, httpx.WithMeterProvider(t.MeterProvider)))
+
+	if err := http.ListenAndServe(":9003", &root); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// handleNotification delivers a notification taken from the queue.
+func handleNotification(ctx context.Context, m queue.Message) error {
+	var p struct {
+		CardID string `json:"card_id"`
+	}
+	if err := json.Unmarshal(m.Body, &p); err != nil {
+		return err
+	}
+
+	if err := save(ctx, p.CardID); err != nil {
+		return err
+	}
+
+	status, err := checkFraud(ctx, p.CardID)
+	if err != nil {
+		return err
+	}
+	if status != "active" {
+		return fmt.Errorf("card status %q", status)
+	}
+	return nil
+}
+
 func checkFraud(ctx context.Context, cardID string) (string, error) {
-	_, span := tracer.Start(ctx, "check-fraud")
+	ctx, span := tracer.Start(ctx, "check-fraud")
 	defer span.End()
 
-	req, _ := http.NewRequest("GET",
+	ctx, cancelFn := httpx.WithDefaultTimeout(ctx, 3*time.Second)
+	defer cancelFn()
+
+	if fraudClient != nil {
+		res, err := fraudClient.GetCardStatus(ctx, &fraudpb.GetCardStatusRequest{CardId: cardID})
+		if err != nil {
+			return "", grpcx.CheckError(span, err)
+		}
+		return res.Status, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET",
 		"http://localhost:9001/api/fraud",
 		strings.NewReader(fmt.Sprintf(`{"card_id":"%s"}`, cardID)),
 	)
-
-	ctx, cancelFn := context.WithTimeout(ctx, 3*time.Second)
-	defer cancelFn()
-
-	req.WithContext(ctx)
+	if err != nil {
+		return "", err
+	}
 	req.Header.Set("content-type", "application/json")
 
-	response, err := http.DefaultClient.Do(req)
+	response, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
+	defer response.Body.Close()
 
 	b, _ := io.ReadAll(response.Body)
+	if err := httpx.CheckResponse(span, response); err != nil {
+		return "", err
+	}
 
 	var p struct {
 		Status string `json:"status"`
@@ -174,26 +233,24 @@ func save(ctx context.Context, cardID string) error {
 	defer span.End()
 
 	s := rand.Intn(5)
-	time.Sleep(time.Duration(s) * time.Second)
+	select {
+	case <-time.After(time.Duration(s) * time.Second):
+	case <-ctx.Done():
+		span.RecordError(ctx.Err())
+		reason := reasonCanceled
+		if httpx.IsDeadlineExceeded(ctx.Err()) {
+			httpx.SetDeadlineExceeded(span)
+			reason = reasonDeadlineExceeded
+		}
+		notificationSaveFailures.Add(ctx, 1, reasonAttr(reason))
+		return ctx.Err()
+	}
 
 	const timeout int = 2
 	if s > timeout {
+		notificationSaveFailures.Add(ctx, 1, reasonAttr(reasonTimeout))
 		return errors.New("timeout saving notification")
 	}
 
 	return nil
 }
-
-// newResource returns a resource describing this application.
-func newResource() *resource.Resource {
-	r, _ := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String("notification"),
-			semconv.ServiceVersionKey.String("v0.1.0"),
-			attribute.String("environment", "staging"),
-		),
-	)
-	return r
-}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+// The notification metrics, served on /metrics with the RED ones:
+//
+//	notifications_save_failures_total{reason}  counter of notifications
+//	                                           that could not be saved,
+//	                                           reason being one of the
+//	                                           constants below
+var notificationSaveFailures syncint64.Counter
+
+// Reasons a notification is not saved.
+const (
+	reasonTimeout          = "timeout"
+	reasonDeadlineExceeded = "deadline_exceeded"
+	reasonCanceled         = "canceled"
+)
+
+func newMetrics(mp metric.MeterProvider) (err error) {
+	notificationSaveFailures, err = mp.Meter(name).SyncInt64().Counter("notifications.save.failures",
+		instrument.WithDescription("Notifications that could not be saved, by reason"))
+	return err
+}
+
+// reasonAttr returns the reason attribute of a failure.
+func reasonAttr(reason string) attribute.KeyValue {
+	return attribute.String("reason", reason)
+}
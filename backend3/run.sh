@@ -1,3 +1,6 @@
 #!/bin/bash
 
-OTEL_EXPORTER_OTLP_ENDPOINT=localhost:4317 go run main.go
+OTEL_TRACES_EXPORTER=otlp-grpc \
+OTEL_EXPORTER_OTLP_ENDPOINT=http://localhost:4317 \
+OTEL_RESOURCE_ATTRIBUTES=deployment.environment=staging \
+go run main.go
@@ -1,45 +1,80 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"github.com/prometheus/client_golang/prometheus/push"
 	"log"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+
+	"opentelemetry/internal/pusher"
+	"opentelemetry/internal/telemetry"
 )
 
+const name = "dummy"
+
 var (
-	dummyGaugeMetric = promauto.NewGauge(prometheus.GaugeOpts{
-		Name: "dummy_gauge_metric",
-		Help: "A dummy gauge metric",
-	})
+	// dummyGaugeValue is the last value of dummy_gauge_metric, observed at
+	// collection.
+	dummyGaugeValue int64
 
-	dummyCounterVecMetric = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "dummy_countervec_metric",
-		Help: "A dummy countervec metric",
-	},
-		[]string{"instance", "cprovider"},
-	)
+	dummyCounterVecMetric syncint64.Counter
 
-	dummyCounterMetric = prometheus.NewCounter(prometheus.CounterOpts{
-		Name: "non_scrappable_counter_metric",
-		Help: "A dummy non scrappable counter metric",
-	})
+	dummyCounterMetric syncint64.Counter
 )
 
+// newScrappableMetrics creates the instruments served on /metrics.
+func newScrappableMetrics(mp metric.MeterProvider) error {
+	meter := mp.Meter(name)
+
+	dummyGaugeMetric, err := meter.AsyncInt64().Gauge("dummy_gauge_metric",
+		instrument.WithDescription("A dummy gauge metric"))
+	if err != nil {
+		return err
+	}
+	err = meter.RegisterCallback([]instrument.Asynchronous{dummyGaugeMetric}, func(ctx context.Context) {
+		dummyGaugeMetric.Observe(ctx, atomic.LoadInt64(&dummyGaugeValue))
+	})
+	if err != nil {
+		return err
+	}
+
+	dummyCounterVecMetric, err = meter.SyncInt64().Counter("dummy_countervec_metric",
+		instrument.WithDescription("A dummy countervec metric"))
+	return err
+}
+
+// newPushedMetrics creates the instruments pushed to the Pushgateway.
+func newPushedMetrics(mp metric.MeterProvider) (err error) {
+	dummyCounterMetric, err = mp.Meter(name).SyncInt64().Counter("non_scrappable_counter_metric",
+		instrument.WithDescription("A dummy non scrappable counter metric"))
+	return err
+}
+
 func recordScrappableMetrics() {
 	go func() {
+		ctx := context.Background()
 		for {
 			r := rand.Intn(2)
 			a := []string{"instance_a", "instance_b"}[r]
 			b := []string{"gpc", "aws"}[r]
-			dummyCounterVecMetric.WithLabelValues(a, b).Add(float64(rand.Intn(50)))
-			dummyGaugeMetric.Set(float64(rand.Intn(502)))
+			dummyCounterVecMetric.Add(ctx, int64(rand.Intn(50)),
+				attribute.String("instance", a),
+				attribute.String("cprovider", b),
+			)
+			atomic.StoreInt64(&dummyGaugeValue, int64(rand.Intn(502)))
 
 			time.Sleep(2 * time.Second)
 		}
@@ -47,34 +82,74 @@ func recordScrappableMetrics() {
 }
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The instruments are collected by Prometheus registries through the
+	// bridge, so they keep the names and labels they had as client_golang
+	// metrics.
+	mp, err := telemetry.NewPrometheusBridge(prometheus.DefaultRegisterer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := newScrappableMetrics(mp); err != nil {
+		log.Fatal(err)
+	}
 	recordScrappableMetrics()
 
 	// We use a registry here to benefit from the consistency checks that
 	// happen during registration.
 	registry := prometheus.NewRegistry()
-	registry.MustRegister(dummyCounterMetric)
-
-	pusher := push.New("http://localhost:9091", "dummy_non_scrappable_target").Gatherer(registry)
+	pushed, err := telemetry.NewPrometheusBridge(registry)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := newPushedMetrics(pushed); err != nil {
+		log.Fatal(err)
+	}
 
 	go func() {
 		t := time.NewTicker(2 * time.Second)
+		defer t.Stop()
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case <-t.C:
-				dummyCounterMetric.Inc()
-
-				if err := pusher.Add(); err != nil {
-					fmt.Println("could not push to pushgateway:", err)
-				} else {
-					fmt.Println("pushed metric to pushgateway successfully")
-				}
-
-			default:
+				dummyCounterMetric.Add(ctx, 1)
 			}
 		}
-
 	}()
 
+	p, err := pusher.New(registry, pusher.Config{
+		URL:              "http://localhost:9091",
+		Job:              "dummy_non_scrappable_target",
+		Mode:             pusher.ModeAdd,
+		Interval:         2 * time.Second,
+		DeleteOnShutdown: true,
+		MeterProvider:    mp,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	http.Handle("/metrics", promhttp.Handler())
-	log.Fatal(http.ListenAndServe(":9004", nil))
+	srv := &http.Server{Addr: ":9004"}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	fmt.Println("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("http server:", err)
+	}
+	if err := p.Shutdown(shutdownCtx); err != nil {
+		log.Println(err)
+	}
 }
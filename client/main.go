@@ -1,17 +1,68 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"opentelemetry/internal/httpx"
+	"opentelemetry/internal/telemetry"
+)
+
+const name = "payments-client"
+
+// paymentTimeout is the budget of each payment, passed on from hop to hop.
+// It covers the slowest chain: scoring (0.8s) and three simulated saves of
+// up to 4s each in fraud, notification and payments, plus the card status
+// check.
+const paymentTimeout = 15 * time.Second
+
+var (
+	tracer trace.Tracer
+	prop   propagation.TextMapPropagator
+
+	// failedTraces collects the trace IDs of failed payments, to be
+	// looked up in Jaeger.
+	failedMu     sync.Mutex
+	failedTraces []string
 )
 
 func main() {
+	l := log.New(os.Stdout, "", 0)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	t, err := telemetry.Setup(ctx, telemetry.Config{
+		ServiceName: "payments-client",
+	})
+	if err != nil {
+		l.Fatal(err)
+	}
+	defer func() {
+		if err := t.Shutdown(context.Background()); err != nil {
+			l.Fatal(err)
+		}
+	}()
+
+	tracer = t.TracerProvider.Tracer(name)
+	prop = t.Propagator
 
 	fmt.Println("creating clients")
 	var wg sync.WaitGroup
@@ -19,49 +70,126 @@ func main() {
 	var count = 1
 	wg.Add(count)
 	for i := 0; i < count; i++ {
-		go doWork()
+		go func() {
+			defer wg.Done()
+			doWork(ctx)
+		}()
 	}
 
 	wg.Wait()
+
+	if len(failedTraces) > 0 {
+		fmt.Println(len(failedTraces), "payments failed, trace ids:")
+		for _, id := range failedTraces {
+			fmt.Println(id)
+		}
+	}
+}
+
+// paymentFailed logs a failed payment with the ID of its trace, as reported
+// by the server when there was a response.
+func paymentFailed(span trace.Span, res *http.Response, msg string) {
+	traceID := span.SpanContext().TraceID().String()
+	if res != nil {
+		if id := httpx.TraceIDFromResponse(res); id != "" {
+			traceID = id
+		}
+	}
+
+	failedMu.Lock()
+	failedTraces = append(failedTraces, traceID)
+	failedMu.Unlock()
+
+	fmt.Println(msg, "trace_id", traceID)
 }
 
-func doWork() {
+func doWork(ctx context.Context) {
 	t := time.NewTicker(500 * time.Millisecond)
+	defer t.Stop()
+
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-t.C:
+			inflight.Add(1)
 			go func() {
-				cardID := rand.Int()
-				amount := rand.Intn(5000)
-
-				fmt.Println("sending payment card_id", cardID, "and amount", amount)
-
-				response, err := http.Post("http://localhost:9000/api/payment",
-					"application/json",
-					strings.NewReader(fmt.Sprintf(`{"card_id":"%d", "amount":"%d"}`, cardID, amount)))
+				defer inflight.Done()
+				createPayment(context.Background(), strconv.Itoa(rand.Int()), rand.Intn(5000))
+			}()
+		}
+	}
+}
 
-				if err != nil || response.StatusCode != http.StatusOK {
-					fmt.Println("error creating payment")
-					return
-				}
+// createPayment sends one synthetic payment inside a root CLIENT span, so
+// that the trace starts where the latency is observed.
+func createPayment(ctx context.Context, cardID string, amount int) {
+	ctx, span := tracer.Start(ctx, "HTTP POST /api/payment",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("card_id", cardID),
+			attribute.Int("amount", amount),
+		),
+	)
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, paymentTimeout)
+	defer cancel()
+
+	fmt.Println("sending payment card_id", cardID, "and amount", amount)
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		"http://localhost:9000/api/payment",
+		strings.NewReader(fmt.Sprintf(`{"card_id":"%s", "amount":"%d"}`, cardID, amount)),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	req.Header.Set("content-type", "application/json")
+	span.SetAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...)
+	prop.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	err = httpx.SetTimeout(req)
+	var response *http.Response
+	if err == nil {
+		response, err = http.DefaultClient.Do(req)
+	}
+	if err != nil {
+		span.RecordError(err)
+		if httpx.IsDeadlineExceeded(err) {
+			httpx.SetDeadlineExceeded(span)
+		} else {
+			span.SetStatus(codes.Error, err.Error())
+		}
 
-				b, _ := io.ReadAll(response.Body)
-				var p struct {
-					ID string `json:"id"`
-				}
+		paymentFailed(span, nil, "error creating payment")
+		return
+	}
+	defer response.Body.Close()
 
-				if err := json.Unmarshal(b, &p); err != nil {
-					fmt.Println("error payment response", err.Error(), "body", b)
-					return
-				}
+	httpx.SetClientStatus(span, response.StatusCode)
+	if err := httpx.CheckResponse(span, response); err != nil {
+		paymentFailed(span, response, "error creating payment")
+		return
+	}
 
-				fmt.Println("payment id", p.ID, "created successfully")
-			}()
+	b, _ := io.ReadAll(response.Body)
+	var p struct {
+		ID string `json:"id"`
+	}
 
-		default:
+	if err := json.Unmarshal(b, &p); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-		}
+		paymentFailed(span, response, fmt.Sprintf("error payment response %s body %s", err, b))
+		return
 	}
 
+	fmt.Println("payment id", p.ID, "created successfully")
 }
@@ -0,0 +1,5 @@
+// Package fraudpb holds the gRPC API of the fraud service, generated from
+// fraud.proto.
+package fraudpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative fraud.proto
@@ -0,0 +1,147 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.20.1
+// source: fraud.proto
+
+package fraudpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// FraudServiceClient is the client API for FraudService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FraudServiceClient interface {
+	// Score decides whether a payment is approved and notifies the card
+	// holder.
+	Score(ctx context.Context, in *ScoreRequest, opts ...grpc.CallOption) (*ScoreResponse, error)
+	// GetCardStatus returns the status of a card.
+	GetCardStatus(ctx context.Context, in *GetCardStatusRequest, opts ...grpc.CallOption) (*GetCardStatusResponse, error)
+}
+
+type fraudServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFraudServiceClient(cc grpc.ClientConnInterface) FraudServiceClient {
+	return &fraudServiceClient{cc}
+}
+
+func (c *fraudServiceClient) Score(ctx context.Context, in *ScoreRequest, opts ...grpc.CallOption) (*ScoreResponse, error) {
+	out := new(ScoreResponse)
+	err := c.cc.Invoke(ctx, "/fraud.v1.FraudService/Score", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fraudServiceClient) GetCardStatus(ctx context.Context, in *GetCardStatusRequest, opts ...grpc.CallOption) (*GetCardStatusResponse, error) {
+	out := new(GetCardStatusResponse)
+	err := c.cc.Invoke(ctx, "/fraud.v1.FraudService/GetCardStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FraudServiceServer is the server API for FraudService service.
+// All implementations must embed UnimplementedFraudServiceServer
+// for forward compatibility
+type FraudServiceServer interface {
+	// Score decides whether a payment is approved and notifies the card
+	// holder.
+	Score(context.Context, *ScoreRequest) (*ScoreResponse, error)
+	// GetCardStatus returns the status of a card.
+	GetCardStatus(context.Context, *GetCardStatusRequest) (*GetCardStatusResponse, error)
+	mustEmbedUnimplementedFraudServiceServer()
+}
+
+// UnimplementedFraudServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedFraudServiceServer struct {
+}
+
+func (UnimplementedFraudServiceServer) Score(context.Context, *ScoreRequest) (*ScoreResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Score not implemented")
+}
+func (UnimplementedFraudServiceServer) GetCardStatus(context.Context, *GetCardStatusRequest) (*GetCardStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCardStatus not implemented")
+}
+func (UnimplementedFraudServiceServer) mustEmbedUnimplementedFraudServiceServer() {}
+
+// UnsafeFraudServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FraudServiceServer will
+// result in compilation errors.
+type UnsafeFraudServiceServer interface {
+	mustEmbedUnimplementedFraudServiceServer()
+}
+
+func RegisterFraudServiceServer(s grpc.ServiceRegistrar, srv FraudServiceServer) {
+	s.RegisterService(&FraudService_ServiceDesc, srv)
+}
+
+func _FraudService_Score_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FraudServiceServer).Score(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fraud.v1.FraudService/Score",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FraudServiceServer).Score(ctx, req.(*ScoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FraudService_GetCardStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCardStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FraudServiceServer).GetCardStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/fraud.v1.FraudService/GetCardStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FraudServiceServer).GetCardStatus(ctx, req.(*GetCardStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// FraudService_ServiceDesc is the grpc.ServiceDesc for FraudService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FraudService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fraud.v1.FraudService",
+	HandlerType: (*FraudServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Score",
+			Handler:    _FraudService_Score_Handler,
+		},
+		{
+			MethodName: "GetCardStatus",
+			Handler:    _FraudService_GetCardStatus_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "fraud.proto",
+}
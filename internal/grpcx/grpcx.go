@@ -0,0 +1,92 @@
+// Package grpcx holds the gRPC plumbing the services share, the counterpart
+// of httpx for services that talk gRPC.
+package grpcx
+
+import (
+	"context"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"opentelemetry/internal/httpx"
+)
+
+// NewServer returns a gRPC server that handles every call inside a SERVER
+// span continuing the trace extracted from the call metadata with prop.
+// The caller's deadline arrives in grpc-timeout and is already applied to
+// the handler context by gRPC itself.
+func NewServer(tp trace.TracerProvider, prop propagation.TextMapPropagator) *grpc.Server {
+	return grpc.NewServer(
+		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor(
+			otelgrpc.WithTracerProvider(tp),
+			otelgrpc.WithPropagators(prop),
+		)),
+	)
+}
+
+// Dial connects to the plaintext gRPC server at addr. Every call is
+// recorded as a CLIENT span and carries its trace context, injected with
+// prop, and its deadline. Calls that run out of time fail with an error
+// matching context.DeadlineExceeded.
+func Dial(ctx context.Context, addr string, tp trace.TracerProvider, prop propagation.TextMapPropagator) (*grpc.ClientConn, error) {
+	return grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(
+			otelgrpc.UnaryClientInterceptor(
+				otelgrpc.WithTracerProvider(tp),
+				otelgrpc.WithPropagators(prop),
+			),
+			deadlineErrors,
+		),
+	)
+}
+
+// deadlineErrors makes DeadlineExceeded statuses match
+// context.DeadlineExceeded, so that callers handle them the same way
+// whatever the transport.
+func deadlineErrors(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if st, ok := status.FromError(err); ok && st.Code() == codes.DeadlineExceeded {
+		return deadlineError{st}
+	}
+	return err
+}
+
+type deadlineError struct {
+	st *status.Status
+}
+
+func (e deadlineError) Error() string              { return e.st.Err().Error() }
+func (e deadlineError) Unwrap() error              { return context.DeadlineExceeded }
+func (e deadlineError) GRPCStatus() *status.Status { return e.st }
+
+// Error converts err into a gRPC status error, DeadlineExceeded when it
+// comes from a deadline that passed and Internal otherwise.
+func Error(err error) error {
+	if httpx.IsDeadlineExceeded(err) {
+		return status.Error(codes.DeadlineExceeded, httpx.DeadlineExceeded)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// CheckError records err, returned by a call, on span and returns it, as
+// httpx.CheckResponse does for HTTP, so that the span around the call fails
+// with it. The CLIENT span of the call has it already.
+func CheckError(span trace.Span, err error) error {
+	if err == nil {
+		return nil
+	}
+	span.RecordError(err)
+	if httpx.IsDeadlineExceeded(err) {
+		httpx.SetDeadlineExceeded(span)
+	} else {
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	return err
+}
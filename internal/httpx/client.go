@@ -0,0 +1,33 @@
+// Package httpx holds the HTTP plumbing the services share, so that every
+// hop is traced and propagated the same way.
+package httpx
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewClient returns an HTTP client that records every request as a CLIENT
+// span, child of the span in the request context, and injects that context
+// into the request headers with prop. Deadlines and cancellation also come
+// from the request context, so requests must be built with
+// http.NewRequestWithContext; the deadline is sent in TimeoutHeader.
+//
+// The span ends when the response body is closed or read to the end.
+func NewClient(tp trace.TracerProvider, prop propagation.TextMapPropagator) *http.Client {
+	return &http.Client{
+		Transport: otelhttp.NewTransport(deadlineTransport{base: http.DefaultTransport},
+			otelhttp.WithTracerProvider(tp),
+			otelhttp.WithPropagators(prop),
+			otelhttp.WithSpanNameFormatter(clientSpanName),
+		),
+	}
+}
+
+// clientSpanName names client spans like the server spans they lead to.
+func clientSpanName(_ string, r *http.Request) string {
+	return "HTTP " + r.Method + " " + r.URL.Path
+}
@@ -0,0 +1,155 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TimeoutHeader carries the time a caller is still willing to wait for a
+// response, encoded like grpc-timeout: at most eight digits followed by one
+// of the units H, M, S, m, u or n.
+const TimeoutHeader = "Request-Timeout"
+
+// DeadlineRemainingKey records, in milliseconds, the budget left when a
+// request was sent or received.
+const DeadlineRemainingKey = attribute.Key("deadline.remaining_ms")
+
+// DeadlineExceeded is the span status description of work given up because
+// the caller's deadline passed.
+const DeadlineExceeded = "deadline exceeded"
+
+var timeoutUnits = []struct {
+	unit byte
+	d    time.Duration
+}{
+	{'n', time.Nanosecond},
+	{'u', time.Microsecond},
+	{'m', time.Millisecond},
+	{'S', time.Second},
+	{'M', time.Minute},
+	{'H', time.Hour},
+}
+
+// encodeTimeout uses the finest unit that fits in eight digits, rounding
+// up so that the callee never sees more time than there is.
+func encodeTimeout(d time.Duration) string {
+	for _, u := range timeoutUnits {
+		if n := (d + u.d - 1) / u.d; n < 1e8 {
+			return strconv.FormatInt(int64(n), 10) + string(u.unit)
+		}
+	}
+	return "99999999H"
+}
+
+func decodeTimeout(s string) (time.Duration, error) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	for _, u := range timeoutUnits {
+		if u.unit == s[len(s)-1] {
+			return time.Duration(n) * u.d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid timeout unit in %q", s)
+}
+
+// SetTimeout sets TimeoutHeader on req from the deadline of its context.
+// It fails with context.DeadlineExceeded when that deadline has passed.
+func SetTimeout(req *http.Request) error {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return nil
+	}
+
+	remaining := time.Until(deadline)
+	trace.SpanFromContext(req.Context()).SetAttributes(DeadlineRemainingKey.Int64(remaining.Milliseconds()))
+	if remaining <= 0 {
+		return context.DeadlineExceeded
+	}
+	req.Header.Set(TimeoutHeader, encodeTimeout(remaining))
+	return nil
+}
+
+// deadlineTransport sends the deadline of each request along with it, and
+// does not send requests whose deadline already passed.
+type deadlineTransport struct {
+	base http.RoundTripper
+}
+
+func (t deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Deadline(); ok {
+		// A RoundTripper must not modify the caller's request.
+		req = req.Clone(req.Context())
+		if err := SetTimeout(req); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// withDeadline applies the caller's TimeoutHeader to the request context,
+// and answers 504 without calling h when no budget is left.
+func withDeadline(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get(TimeoutHeader)
+		if v == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		span := trace.SpanFromContext(r.Context())
+		timeout, err := decodeTimeout(v)
+		if err != nil {
+			span.RecordError(err)
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		remaining := time.Until(deadline)
+		span.SetAttributes(DeadlineRemainingKey.Int64(remaining.Milliseconds()))
+		if remaining <= 0 {
+			SetDeadlineExceeded(span)
+			http.Error(w, DeadlineExceeded, http.StatusGatewayTimeout)
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithDefaultTimeout returns ctx as is when it already has a deadline,
+// usually the caller's from TimeoutHeader, so that the caller's budget
+// bounds the calls made with it, and ctx limited to timeout otherwise.
+func WithDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// IsDeadlineExceeded reports whether err comes from a deadline that passed.
+func IsDeadlineExceeded(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// SetDeadlineExceeded marks span as given up on because its deadline
+// passed, so such failures can be told apart from real errors.
+func SetDeadlineExceeded(span trace.Span) {
+	span.SetStatus(codes.Error, DeadlineExceeded)
+}
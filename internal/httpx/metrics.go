@@ -0,0 +1,74 @@
+package httpx
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+// serverMetrics are the RED metrics of a server: requests, errors and
+// duration, by route, method and status code.
+type serverMetrics struct {
+	requests syncint64.Counter
+	errors   syncint64.Counter
+	duration syncfloat64.Histogram
+}
+
+func newServerMetrics(mp metric.MeterProvider) (*serverMetrics, error) {
+	meter := mp.Meter("opentelemetry/internal/httpx")
+
+	var m serverMetrics
+	var err error
+	if m.requests, err = meter.SyncInt64().Counter("http.server.requests",
+		instrument.WithDescription("HTTP requests served")); err != nil {
+		return nil, err
+	}
+	if m.errors, err = meter.SyncInt64().Counter("http.server.errors",
+		instrument.WithDescription("HTTP requests answered with a 5xx status")); err != nil {
+		return nil, err
+	}
+	if m.duration, err = meter.SyncFloat64().Histogram("http.server.duration",
+		instrument.WithDescription("Time taken to serve HTTP requests, in seconds"),
+		instrument.WithUnit(unit.Unit("s"))); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// withMetrics records the RED metrics of every request h serves. The route
+// is the mux pattern that matched, so that paths with IDs in them do not
+// each make new series.
+func withMetrics(h http.Handler, mux *http.ServeMux, mp metric.MeterProvider) http.Handler {
+	m, err := newServerMetrics(mp)
+	if err != nil {
+		otel.Handle(err)
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		_, route := mux.Handler(r)
+		attrs := []attribute.KeyValue{
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPRouteKey.String(route),
+			semconv.HTTPStatusCodeKey.Int(rec.code),
+		}
+		ctx := r.Context()
+		m.requests.Add(ctx, 1, attrs...)
+		if rec.code >= 500 {
+			m.errors.Add(ctx, 1, attrs...)
+		}
+		m.duration.Record(ctx, time.Since(start).Seconds(), attrs...)
+	})
+}
@@ -0,0 +1,90 @@
+package httpx
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/nonrecording"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type handlerConfig struct {
+	traceIDHeader string
+	meterProvider metric.MeterProvider
+}
+
+// HandlerOption configures NewHandler.
+type HandlerOption func(*handlerConfig)
+
+// WithTraceIDHeader sets the response header that carries the bare trace
+// ID, in addition to traceresponse. An empty name disables it.
+func WithTraceIDHeader(name string) HandlerOption {
+	return func(c *handlerConfig) {
+		c.traceIDHeader = name
+	}
+}
+
+// WithMeterProvider sets the meter provider of the RED metrics. It defaults
+// to the global one.
+func WithMeterProvider(mp metric.MeterProvider) HandlerOption {
+	return func(c *handlerConfig) {
+		c.meterProvider = mp
+	}
+}
+
+// NewHandler serves mux inside a SERVER span per request that continues the
+// trace extracted from the request headers with prop. Spans are named after
+// the method and the mux pattern that matched, and get http.route when the
+// handler was registered through otelhttp.WithRouteTag. Every response
+// names its trace in the traceresponse and X-Trace-Id headers, and the
+// caller's TimeoutHeader becomes the deadline of the request context. The
+// span fails on 5xx responses only. Requests, errors and durations are
+// counted by route, method and status code.
+//
+// Handlers reach the span with trace.SpanFromContext(r.Context()) and should
+// record their errors on it rather than start a span of their own.
+func NewHandler(mux *http.ServeMux, tp trace.TracerProvider, prop propagation.TextMapPropagator, opts ...HandlerOption) http.Handler {
+	cfg := handlerConfig{
+		traceIDHeader: DefaultTraceIDHeader,
+		meterProvider: global.MeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	h := withMetrics(withStatus(traceResponse(withDeadline(mux), cfg.traceIDHeader)), mux, cfg.meterProvider)
+	return otelhttp.NewHandler(h, "",
+		otelhttp.WithTracerProvider(serverTracerProvider{tp}),
+		otelhttp.WithPropagators(prop),
+		// withMetrics replaces the metrics of otelhttp, which have neither
+		// the route nor the status code.
+		otelhttp.WithMeterProvider(nonrecording.NewNoopMeterProvider()),
+		otelhttp.WithMessageEvents(otelhttp.ReadEvents, otelhttp.WriteEvents),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if _, pattern := mux.Handler(r); pattern != "" {
+				return "HTTP " + r.Method + " " + pattern
+			}
+			return "HTTP " + r.Method
+		}),
+	)
+}
+
+// Error records err on span and answers the request with code, or with 504
+// and the DeadlineExceeded status when err comes from a deadline that
+// passed. Only 5xx codes fail span; a 4xx is the client's error.
+func Error(w http.ResponseWriter, span trace.Span, err error, code int) {
+	span.RecordError(err)
+	if IsDeadlineExceeded(err) {
+		SetDeadlineExceeded(span)
+		http.Error(w, DeadlineExceeded, http.StatusGatewayTimeout)
+		return
+	}
+	if code >= 500 {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	http.Error(w, err.Error(), code)
+}
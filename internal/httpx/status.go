@@ -0,0 +1,133 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StatusError is the error of a request answered outside 2xx.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status " + e.Status
+}
+
+// CheckResponse returns a *StatusError when res is not a 2xx response, and
+// records it on span, so that the calling service fails instead of carrying
+// on as if the downstream call worked.
+func CheckResponse(span trace.Span, res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	status := res.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	err := &StatusError{StatusCode: res.StatusCode, Status: status}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
+
+// SetClientStatus records code on a CLIENT span made by hand, and marks it
+// as failed for 4xx and 5xx responses. Spans of NewClient get it already.
+func SetClientStatus(span trace.Span, code int) {
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(code)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(code, trace.SpanKindClient))
+}
+
+// serverTracerProvider hands otelhttp SERVER spans whose status follows the
+// HTTP conventions for servers: only 5xx responses are errors, as a 4xx is
+// the client's fault. otelhttp itself fails 4xx responses too, and drops
+// the description of an error status set by the handler.
+type serverTracerProvider struct {
+	trace.TracerProvider
+}
+
+func (p serverTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return serverTracer{p.TracerProvider.Tracer(name, opts...)}
+}
+
+type serverTracer struct {
+	trace.Tracer
+}
+
+func (t serverTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	ctx, span := t.Tracer.Start(ctx, name, opts...)
+	s := &serverSpan{Span: span}
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+// serverSpan takes the status set by the handler, and once the handler
+// returns, derives it from the response code with served and ignores the
+// one otelhttp sets.
+type serverSpan struct {
+	trace.Span
+
+	mu     sync.Mutex
+	failed bool
+	done   bool
+}
+
+func (s *serverSpan) SetStatus(code codes.Code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	s.failed = code == codes.Error
+	s.Span.SetStatus(code, description)
+}
+
+func (s *serverSpan) served(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if code >= 500 && !s.failed {
+		s.Span.SetStatus(codes.Error, http.StatusText(code))
+	}
+	s.done = true
+}
+
+// withStatus sets the status of the server span from the code h answers
+// with.
+func withStatus(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := trace.SpanFromContext(r.Context()).(*serverSpan)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		s.served(rec.code)
+	})
+}
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,54 @@
+package httpx
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceResponseHeader is the response header defined by W3C Trace Context
+// Level 2. It names the trace and the server span that handled a request.
+const TraceResponseHeader = "traceresponse"
+
+// DefaultTraceIDHeader is the plain trace ID header set by NewHandler unless
+// WithTraceIDHeader says otherwise.
+const DefaultTraceIDHeader = "X-Trace-Id"
+
+// traceResponse sets the trace headers from the span in the request context
+// before calling h, so that they are sent with every response, errors
+// included.
+func traceResponse(h http.Handler, traceIDHeader string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			w.Header().Set(TraceResponseHeader, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-"+sc.TraceFlags().String())
+			if traceIDHeader != "" {
+				w.Header().Set(traceIDHeader, sc.TraceID().String())
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// TraceIDFromResponse returns the trace ID a server reported for res, from
+// the traceresponse header or else the X-Trace-Id one, or "" when it
+// reported none.
+func TraceIDFromResponse(res *http.Response) string {
+	return TraceIDFromResponseHeader(res, DefaultTraceIDHeader)
+}
+
+// TraceIDFromResponseHeader is TraceIDFromResponse for servers that send
+// the bare trace ID in traceIDHeader, as set with WithTraceIDHeader. An
+// empty traceIDHeader only reads traceresponse.
+func TraceIDFromResponseHeader(res *http.Response, traceIDHeader string) string {
+	parts := strings.Split(res.Header.Get(TraceResponseHeader), "-")
+	if len(parts) == 4 {
+		if id, err := trace.TraceIDFromHex(parts[1]); err == nil {
+			return id.String()
+		}
+	}
+	if traceIDHeader == "" {
+		return ""
+	}
+	return res.Header.Get(traceIDHeader)
+}
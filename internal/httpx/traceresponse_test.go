@@ -0,0 +1,86 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceIDFromResponseHeader(t *testing.T) {
+	const id = "0102030405060708090a0b0c0d0e0f10"
+
+	tests := []struct {
+		name   string
+		header http.Header
+		read   string
+		want   string
+	}{
+		{
+			name:   "traceresponse first",
+			header: http.Header{"Traceresponse": {"00-" + id + "-0102030405060708-01"}, "X-Trace-Id": {"other"}},
+			read:   DefaultTraceIDHeader,
+			want:   id,
+		},
+		{
+			name:   "default header",
+			header: http.Header{"X-Trace-Id": {id}},
+			read:   DefaultTraceIDHeader,
+			want:   id,
+		},
+		{
+			name:   "custom header",
+			header: http.Header{"X-Request-Trace": {id}},
+			read:   "X-Request-Trace",
+			want:   id,
+		},
+		{
+			name:   "default header not read for a custom one",
+			header: http.Header{"X-Trace-Id": {id}},
+			read:   "X-Request-Trace",
+		},
+		{
+			name:   "invalid traceresponse falls back",
+			header: http.Header{"Traceresponse": {"00-xyz-0102030405060708-01"}, "X-Request-Trace": {id}},
+			read:   "X-Request-Trace",
+			want:   id,
+		},
+		{
+			name:   "no header name",
+			header: http.Header{"X-Trace-Id": {id}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{Header: tt.header}
+			if got := TraceIDFromResponseHeader(res, tt.read); got != tt.want {
+				t.Errorf("TraceIDFromResponseHeader(%q) = %q, want %q", tt.read, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceIDFromResponseHeaderOfHandler(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	var mux http.ServeMux
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Del(TraceResponseHeader)
+	})
+	srv := httptest.NewServer(NewHandler(&mux, tp, propagation.TraceContext{}, WithTraceIDHeader("X-Request-Trace")))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if got := TraceIDFromResponse(res); got != "" {
+		t.Errorf("TraceIDFromResponse() = %q, want none", got)
+	}
+	if got := TraceIDFromResponseHeader(res, "X-Request-Trace"); len(got) != 32 {
+		t.Errorf("TraceIDFromResponseHeader() = %q, want a trace ID", got)
+	}
+}
@@ -0,0 +1,252 @@
+// Package pusher publishes metrics to a Prometheus Pushgateway on an
+// interval, for jobs that cannot be scraped.
+package pusher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+// Mode is how a push treats the metrics already in the group.
+type Mode int
+
+const (
+	// ModeAdd replaces only the metrics with the names pushed, like
+	// push.Pusher.Add.
+	ModeAdd Mode = iota
+	// ModePush replaces the whole group, like push.Pusher.Push.
+	ModePush
+)
+
+// Config describes where and how metrics are pushed. Zero fields get the
+// defaults given for each.
+type Config struct {
+	// URL of the Pushgateway and Job of the group, both required.
+	URL string
+	Job string
+	// Grouping holds the labels, besides job, that identify the group.
+	Grouping map[string]string
+	Mode     Mode
+
+	// Interval between pushes, 10s by default.
+	Interval time.Duration
+	// Timeout of each request, 5s by default.
+	Timeout time.Duration
+	// A push that fails on the way or with a 5xx from the gateway is
+	// retried up to MaxAttempts times in all, 4 by default, waiting from InitialBackoff, 250ms by default, twice as long
+	// after every attempt up to MaxBackoff, 5s by default.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// DeleteOnShutdown deletes the group after the final push, so that the
+	// gateway does not keep serving the metrics of a job that is gone.
+	DeleteOnShutdown bool
+
+	// MeterProvider records how pushes went. It defaults to the global one.
+	MeterProvider metric.MeterProvider
+}
+
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = 10 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 4
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = 250 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 5 * time.Second
+	}
+	if c.MeterProvider == nil {
+		c.MeterProvider = global.MeterProvider()
+	}
+	return c
+}
+
+// Pusher pushes the metrics of a gatherer every interval, from New until
+// Shutdown.
+type Pusher struct {
+	cfg      Config
+	gatherer prometheus.Gatherer
+	client   *http.Client
+
+	pushes syncint64.Counter
+
+	// ctx is cancelled by Shutdown, aborting the push in flight.
+	ctx      context.Context
+	stop     context.CancelFunc
+	done     chan struct{}
+	shutdown sync.Once
+}
+
+// New starts pushing the metrics of g as described by cfg.
+func New(g prometheus.Gatherer, cfg Config) (*Pusher, error) {
+	if cfg.URL == "" || cfg.Job == "" {
+		return nil, errors.New("pusher: url and job are required")
+	}
+	cfg = cfg.withDefaults()
+
+	pushes, err := cfg.MeterProvider.Meter("opentelemetry/internal/pusher").SyncInt64().Counter("pusher.pushes",
+		instrument.WithDescription("Pushes to the Pushgateway, by job and result, retries included"))
+	if err != nil {
+		return nil, fmt.Errorf("pusher: metrics: %w", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	p := &Pusher{
+		cfg:      cfg,
+		gatherer: g,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		pushes:   pushes,
+		ctx:      ctx,
+		stop:     stop,
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// pusher returns a push.Pusher for the group whose requests are cancelled
+// with ctx, and the client it sends them with. The push package of this
+// client_golang version takes no context, so it is attached to each request
+// by the HTTP client.
+func (p *Pusher) pusher(ctx context.Context) (*push.Pusher, *ctxDoer) {
+	doer := &ctxDoer{ctx: ctx, client: p.client}
+	pusher := push.New(p.cfg.URL, p.cfg.Job).
+		Gatherer(p.gatherer).
+		Client(doer)
+	for name, value := range p.cfg.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	return pusher, doer
+}
+
+// ctxDoer sends requests with ctx and keeps how the last one went, which
+// the errors of the push package only tell in their text.
+type ctxDoer struct {
+	ctx    context.Context
+	client *http.Client
+
+	status int // of the last response, 0 without one
+	err    error
+}
+
+func (d *ctxDoer) Do(req *http.Request) (*http.Response, error) {
+	res, err := d.client.Do(req.WithContext(d.ctx))
+	d.status, d.err = 0, err
+	if res != nil {
+		d.status = res.StatusCode
+	}
+	return res, err
+}
+
+// retryable tells whether the last request failed in a way another attempt
+// may fix: on the way to the gateway or with a 5xx. A 4xx, or a failure
+// before any request, e.g. gathering the metrics, would only repeat.
+func (d *ctxDoer) retryable() bool {
+	return d.err != nil || d.status >= 500
+}
+
+func (p *Pusher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.push(p.ctx); err != nil {
+				otel.Handle(err)
+			}
+		}
+	}
+}
+
+// push pushes once, retrying with backoff until it succeeds, fails in a
+// way a retry cannot fix, attempts run out or ctx is done.
+func (p *Pusher) push(ctx context.Context) error {
+	pusher, doer := p.pusher(ctx)
+	backoff := p.cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		doer.status, doer.err = 0, nil
+
+		var err error
+		if p.cfg.Mode == ModePush {
+			err = pusher.Push()
+		} else {
+			err = pusher.Add()
+		}
+		p.record(err)
+		if err == nil {
+			return nil
+		}
+		if !doer.retryable() {
+			return fmt.Errorf("pusher: %w", err)
+		}
+		if attempt == p.cfg.MaxAttempts {
+			return fmt.Errorf("pusher: %d attempts failed: %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("pusher: %w", err)
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > p.cfg.MaxBackoff {
+			backoff = p.cfg.MaxBackoff
+		}
+	}
+}
+
+func (p *Pusher) record(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	p.pushes.Add(context.Background(), 1,
+		attribute.String("pushgateway.job", p.cfg.Job),
+		attribute.String("result", result),
+	)
+}
+
+// Shutdown stops the pushes, pushes one last time and, with
+// DeleteOnShutdown, deletes the group even if that push failed. Requests
+// and retries stop when ctx is done.
+func (p *Pusher) Shutdown(ctx context.Context) error {
+	err := errors.New("pusher: already shut down")
+	p.shutdown.Do(func() {
+		p.stop()
+		<-p.done
+
+		err = p.push(ctx)
+		if p.cfg.DeleteOnShutdown {
+			pusher, _ := p.pusher(ctx)
+			if derr := pusher.Delete(); derr != nil && err == nil {
+				err = fmt.Errorf("pusher: delete: %w", derr)
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,101 @@
+package pusher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/metric/nonrecording"
+)
+
+func TestPushRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		// statuses are answered in turn, the last one from then on.
+		statuses     []int
+		wantRequests int32
+		wantErr      bool
+	}{
+		{name: "success", statuses: []int{http.StatusOK}, wantRequests: 1},
+		{name: "5xx retried", statuses: []int{http.StatusServiceUnavailable, http.StatusOK}, wantRequests: 2},
+		{name: "5xx until attempts run out", statuses: []int{http.StatusBadGateway}, wantRequests: 3, wantErr: true},
+		{name: "4xx not retried", statuses: []int{http.StatusBadRequest}, wantRequests: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&requests, 1))
+				if n > len(tt.statuses) {
+					n = len(tt.statuses)
+				}
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			p, err := New(prometheus.NewRegistry(), Config{
+				URL:            srv.URL,
+				Job:            "test",
+				Interval:       time.Hour,
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				MeterProvider:  nonrecording.NewNoopMeterProvider(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.stop()
+
+			err = p.push(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("push() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt32(&requests); got != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestPushRetriesTransportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	p, err := New(prometheus.NewRegistry(), Config{
+		URL:            url,
+		Job:            "test",
+		Interval:       time.Hour,
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		MeterProvider:  nonrecording.NewNoopMeterProvider(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.stop()
+
+	var requests int32
+	p.client.Transport = countingTransport{http.DefaultTransport, &requests}
+	if err := p.push(context.Background()); err == nil {
+		t.Fatal("push() succeeded with the gateway down")
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("sent %d requests, want 2", got)
+	}
+}
+
+// countingTransport counts the requests it sends.
+type countingTransport struct {
+	rt http.RoundTripper
+	n  *int32
+}
+
+func (c countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(c.n, 1)
+	return c.rt.RoundTrip(req)
+}
@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dirPollInterval is how often an empty Dir is checked for new messages.
+const dirPollInterval = 100 * time.Millisecond
+
+// Dir is a queue kept in a local directory, one JSON file per message, so
+// that processes on the same host can share it. Files are named after their
+// publish time and received oldest first. A consumer claims a file by
+// renaming it, so each message goes to a single consumer even when several
+// poll the same directory, and removes it on Ack. Rejected files, and those
+// that cannot be read or decoded, are left as dead letters, with a .dead
+// suffix, for someone to look at. The claimed files of a consumer that
+// died while processing them stay as .claimed, to be renamed back to .msg
+// by hand.
+type Dir struct {
+	dir       string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ Queue = (*Dir)(nil)
+
+// NewDir returns the queue kept in dir, creating the directory if needed.
+func NewDir(dir string) (*Dir, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("queue: %w", err)
+	}
+	return &Dir{dir: dir, done: make(chan struct{})}, nil
+}
+
+func (*Dir) System() string { return "file" }
+
+func (q *Dir) Publish(ctx context.Context, m Message) error {
+	select {
+	case <-q.done:
+		return ErrClosed
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("queue: %w", err)
+	}
+
+	// Write under a name consumers ignore, then rename, so that no consumer
+	// sees a partial message.
+	name := fmt.Sprintf("%020d-%s", time.Now().UnixNano(), m.ID)
+	tmp := filepath.Join(q.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("queue: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, name+".msg")); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("queue: %w", err)
+	}
+	return nil
+}
+
+func (q *Dir) Receive(ctx context.Context) (Message, error) {
+	ticker := time.NewTicker(dirPollInterval)
+	defer ticker.Stop()
+
+	for {
+		m, ok, err := q.claim()
+		if err != nil || ok {
+			return m, err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-q.done:
+			return Message{}, ErrClosed
+		}
+	}
+}
+
+// claim takes the oldest message in the directory, if any, leaving its
+// file claimed until the message is acked or rejected.
+func (q *Dir) claim() (Message, bool, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return Message{}, false, fmt.Errorf("queue: %w", err)
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".msg") {
+			continue
+		}
+
+		path := filepath.Join(q.dir, e.Name())
+		claimed := path + ".claimed"
+		if err := os.Rename(path, claimed); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// Another consumer got it first.
+				continue
+			}
+			return Message{}, false, fmt.Errorf("queue: %w", err)
+		}
+
+		b, err := os.ReadFile(claimed)
+		if err != nil {
+			return Message{}, false, deadLetter(claimed, err)
+		}
+
+		var m Message
+		if err := json.Unmarshal(b, &m); err != nil {
+			return Message{}, false, deadLetter(claimed, fmt.Errorf("decoding %s: %w", e.Name(), err))
+		}
+		m.claimed = claimed
+		return m, true, nil
+	}
+	return Message{}, false, nil
+}
+
+func (q *Dir) Ack(m Message) error {
+	if m.claimed == "" {
+		return fmt.Errorf("queue: message %s was not received from a Dir", m.ID)
+	}
+	if err := os.Remove(m.claimed); err != nil {
+		return fmt.Errorf("queue: %w", err)
+	}
+	return nil
+}
+
+func (q *Dir) Reject(m Message, reason error) error {
+	if m.claimed == "" {
+		return fmt.Errorf("queue: message %s was not received from a Dir", m.ID)
+	}
+	if _, err := moveToDead(m.claimed); err != nil {
+		return fmt.Errorf("queue: rejecting message %s (%v): %w", m.ID, reason, err)
+	}
+	return nil
+}
+
+// deadLetter moves a claimed message that cannot be received aside, so
+// that it is neither retried nor lost, and returns err.
+func deadLetter(claimed string, err error) error {
+	dead, rerr := moveToDead(claimed)
+	if rerr != nil {
+		return fmt.Errorf("queue: %w; moving it aside: %v", err, rerr)
+	}
+	return fmt.Errorf("queue: %w; moved to %s", err, filepath.Base(dead))
+}
+
+// moveToDead renames a claimed message to <name>.msg.dead.
+func moveToDead(claimed string) (string, error) {
+	dead := strings.TrimSuffix(claimed, ".claimed") + ".dead"
+	return dead, os.Rename(claimed, dead)
+}
+
+// Close makes every pending and later call fail with ErrClosed. Messages
+// stay in the directory for the next consumer.
+func (q *Dir) Close() error {
+	q.closeOnce.Do(func() { close(q.done) })
+	return nil
+}
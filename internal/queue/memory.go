@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultMemorySize is the capacity of the queues made by Open.
+const defaultMemorySize = 1024
+
+// Memory is a queue held in memory. Publish blocks while it is full.
+// Rejected messages are kept, see DeadLetters.
+type Memory struct {
+	ch        chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu   sync.Mutex
+	dead []Message
+}
+
+var _ Queue = (*Memory)(nil)
+
+// NewMemory returns an empty queue holding up to size messages.
+func NewMemory(size int) *Memory {
+	return &Memory{
+		ch:   make(chan Message, size),
+		done: make(chan struct{}),
+	}
+}
+
+func (*Memory) System() string { return "memory" }
+
+func (q *Memory) Publish(ctx context.Context, m Message) error {
+	select {
+	case <-q.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case q.ch <- m:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-q.done:
+		return ErrClosed
+	}
+}
+
+func (q *Memory) Receive(ctx context.Context) (Message, error) {
+	select {
+	case m := <-q.ch:
+		return m, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	case <-q.done:
+		return Message{}, ErrClosed
+	}
+}
+
+// Ack does nothing: a received message has already left the channel.
+func (*Memory) Ack(Message) error { return nil }
+
+func (q *Memory) Reject(m Message, _ error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.dead = append(q.dead, m)
+	return nil
+}
+
+// DeadLetters returns the rejected messages, oldest first.
+func (q *Memory) DeadLetters() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]Message(nil), q.dead...)
+}
+
+// Close makes every pending and later call fail with ErrClosed. Messages
+// still in the queue are lost.
+func (q *Memory) Close() error {
+	q.closeOnce.Do(func() { close(q.done) })
+	return nil
+}
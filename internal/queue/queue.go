@@ -0,0 +1,85 @@
+// Package queue moves messages between services without a direct call.
+// Queues are point to point: every message is received by one consumer,
+// which acks it once processed or rejects it to the dead letters, so that
+// a message is never dropped before its handler succeeds. The trace context of the producer travels in the message
+// headers, see Producer and Consume.
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned by the operations of a closed queue.
+var ErrClosed = errors.New("queue: closed")
+
+// Message is what travels through a Queue.
+type Message struct {
+	ID      string            `json:"id"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    []byte            `json:"body"`
+
+	// claimed is where a Dir keeps the message while it is processed.
+	claimed string
+}
+
+// Queue is a point to point message queue.
+type Queue interface {
+	// System names the implementation, for messaging.system.
+	System() string
+	// Publish adds m to the queue.
+	Publish(ctx context.Context, m Message) error
+	// Receive takes the oldest message from the queue, waiting for one
+	// until ctx is done. No other consumer receives it, but it is only
+	// gone once passed to Ack or Reject.
+	Receive(ctx context.Context) (Message, error)
+	// Ack removes m, once processed, from the queue.
+	Ack(m Message) error
+	// Reject moves m, which could not be processed because of reason, to
+	// the dead letters of the queue.
+	Reject(m Message, reason error) error
+	Close() error
+}
+
+var (
+	memoryMu     sync.Mutex
+	memoryQueues = make(map[string]*Memory)
+)
+
+// Open returns the queue named by url: memory://name for a queue shared
+// inside this process, or file://dir for one shared through a local
+// directory. file:///tmp/q is absolute, file://q relative.
+func Open(url string) (Queue, error) {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("queue: invalid url %q", url)
+	}
+
+	switch scheme {
+	case "memory":
+		memoryMu.Lock()
+		defer memoryMu.Unlock()
+		q, ok := memoryQueues[rest]
+		if !ok {
+			q = NewMemory(defaultMemorySize)
+			memoryQueues[rest] = q
+		}
+		return q, nil
+	case "file":
+		return NewDir(rest)
+	default:
+		return nil, fmt.Errorf("queue: unknown scheme %q", scheme)
+	}
+}
+
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("queue: generating message id: %w", err)
+	}
+	return fmt.Sprintf("%x", b), nil
+}
@@ -0,0 +1,131 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "opentelemetry/internal/queue"
+
+// receiveRetryDelay is how long Consume waits after a failed receive, so
+// that a queue failing on every call is not polled in a busy loop.
+const receiveRetryDelay = time.Second
+
+// Producer publishes messages to one queue, each inside a PRODUCER span
+// whose context is injected into the message headers.
+type Producer struct {
+	q           Queue
+	destination string
+	tracer      trace.Tracer
+	prop        propagation.TextMapPropagator
+}
+
+// NewProducer returns a producer for q, which is known to consumers as
+// destination.
+func NewProducer(q Queue, destination string, tp trace.TracerProvider, prop propagation.TextMapPropagator) *Producer {
+	return &Producer{
+		q:           q,
+		destination: destination,
+		tracer:      tp.Tracer(instrumentationName),
+		prop:        prop,
+	}
+}
+
+// Publish sends body as a new message.
+func (p *Producer) Publish(ctx context.Context, body []byte) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	m := Message{ID: id, Headers: make(map[string]string), Body: body}
+
+	ctx, span := p.tracer.Start(ctx, p.destination+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messageAttributes(p.q, p.destination, m)...),
+	)
+	defer span.End()
+
+	p.prop.Inject(ctx, propagation.MapCarrier(m.Headers))
+
+	if err := p.q.Publish(ctx, m); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+// Handler processes one message. Its context carries the CONSUMER span and
+// the baggage of the producer.
+type Handler func(ctx context.Context, m Message) error
+
+// Consume receives messages from q, known as destination, and hands them
+// to h one at a time until ctx is done. Each message is processed inside a
+// CONSUMER span that starts a trace of its own, linked to the producer
+// span, since the work no longer belongs to the request that published it.
+// A message is acked once h succeeds; when h fails the error is recorded on
+// the span and the message rejected to the dead letters. Receive errors, e.g. a message
+// that cannot be decoded, are reported to the OTel error handler and
+// receiving goes on after receiveRetryDelay; Consume only stops when ctx is
+// done or q is closed.
+func Consume(ctx context.Context, q Queue, destination string, tp trace.TracerProvider, prop propagation.TextMapPropagator, h Handler) error {
+	tracer := tp.Tracer(instrumentationName)
+
+	for {
+		m, err := q.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, ErrClosed) {
+				return nil
+			}
+			otel.Handle(err)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(receiveRetryDelay):
+			}
+			continue
+		}
+
+		pctx := prop.Extract(ctx, propagation.MapCarrier(m.Headers))
+		opts := []trace.SpanStartOption{
+			trace.WithNewRoot(),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(messageAttributes(q, destination, m)...),
+			trace.WithAttributes(semconv.MessagingOperationProcess),
+		}
+		if sc := trace.SpanContextFromContext(pctx); sc.IsValid() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+		}
+
+		mctx, span := tracer.Start(pctx, destination+" process", opts...)
+		if err := h(mctx, m); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			if err := q.Reject(m, err); err != nil {
+				otel.Handle(err)
+			}
+		} else if err := q.Ack(m); err != nil {
+			otel.Handle(err)
+		}
+		span.End()
+	}
+}
+
+func messageAttributes(q Queue, destination string, m Message) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.MessagingSystemKey.String(q.System()),
+		semconv.MessagingDestinationKey.String(destination),
+		semconv.MessagingDestinationKindQueue,
+		semconv.MessagingMessageIDKey.String(m.ID),
+		semconv.MessagingMessagePayloadSizeBytesKey.Int(len(m.Body)),
+	}
+}
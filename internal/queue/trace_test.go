@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestConsumeAcksOnlyHandledMessages(t *testing.T) {
+	dir := t.TempDir()
+	dq, err := NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dq.Close()
+	mq := NewMemory(2)
+	defer mq.Close()
+
+	tests := []struct {
+		name string
+		q    Queue
+		// dead returns the bodies of the dead letters of q, and fails when
+		// q still holds any other message.
+		dead func(t *testing.T) []string
+	}{
+		{
+			name: "dir",
+			q:    dq,
+			dead: func(t *testing.T) []string {
+				entries, err := os.ReadDir(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var bodies []string
+				for _, e := range entries {
+					if !strings.HasSuffix(e.Name(), ".msg.dead") {
+						t.Errorf("%s left in the queue", e.Name())
+						continue
+					}
+					b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+					if err != nil {
+						t.Fatal(err)
+					}
+					var m Message
+					if err := json.Unmarshal(b, &m); err != nil {
+						t.Fatal(err)
+					}
+					bodies = append(bodies, string(m.Body))
+				}
+				return bodies
+			},
+		},
+		{
+			name: "memory",
+			q:    mq,
+			dead: func(t *testing.T) []string {
+				if n := len(mq.ch); n != 0 {
+					t.Errorf("%d messages left in the queue", n)
+				}
+				var bodies []string
+				for _, m := range mq.DeadLetters() {
+					bodies = append(bodies, string(m.Body))
+				}
+				return bodies
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := trace.NewNoopTracerProvider()
+			prop := propagation.TraceContext{}
+			p := NewProducer(tt.q, "test", tp, prop)
+			for _, body := range []string{"fail", "ok"} {
+				if err := p.Publish(context.Background(), []byte(body)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var handled []string
+			err := Consume(ctx, tt.q, "test", tp, prop, func(_ context.Context, m Message) error {
+				handled = append(handled, string(m.Body))
+				if m.claimed != "" {
+					if _, err := os.Stat(m.claimed); err != nil {
+						t.Errorf("message %s gone while handled: %v", m.Body, err)
+					}
+				}
+				if string(m.Body) == "fail" {
+					return errors.New("handler failed")
+				}
+				cancel()
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := []string{"fail", "ok"}; !reflect.DeepEqual(handled, want) {
+				t.Errorf("handled %q, want %q", handled, want)
+			}
+			if got, want := tt.dead(t), []string{"fail"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("dead letters = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// baggageProcessor copies the allow-listed baggage members found in the
+// parent context onto every span when it starts, under the member's key.
+// Only members named in the allow list ever become attributes, since
+// baggage arrives from callers and is not trusted. Values are
+// percent-decoded, as callers encode those baggage would not take as is.
+type baggageProcessor struct {
+	keys map[string]bool
+}
+
+var _ sdktrace.SpanProcessor = baggageProcessor{}
+
+// newBaggageProcessor returns a processor for the given member keys, or for
+// the comma separated OTEL_BAGGAGE_SPAN_ATTRIBUTES when keys is empty. It
+// returns nil when neither names any key.
+func newBaggageProcessor(keys []string) sdktrace.SpanProcessor {
+	if len(keys) == 0 {
+		keys = strings.Split(os.Getenv("OTEL_BAGGAGE_SPAN_ATTRIBUTES"), ",")
+	}
+
+	p := baggageProcessor{keys: make(map[string]bool)}
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" {
+			p.keys[k] = true
+		}
+	}
+	if len(p.keys) == 0 {
+		return nil
+	}
+	return p
+}
+
+func (p baggageProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, m := range baggage.FromContext(parent).Members() {
+		if !p.keys[m.Key()] {
+			continue
+		}
+		v, err := url.PathUnescape(m.Value())
+		if err != nil {
+			v = m.Value()
+		}
+		s.SetAttributes(attribute.String(m.Key(), v))
+	}
+}
+
+func (baggageProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (baggageProcessor) Shutdown(context.Context) error   { return nil }
+func (baggageProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBaggageProcessor(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "1234", want: "1234"},
+		{name: "percent-encoded", value: "acme%20corp%2Fuk", want: "acme corp/uk"},
+		{name: "invalid encoding kept as is", value: "100%25%", want: "100%25%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := baggage.NewMember("tenant", tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := baggage.NewMember("secret", "x")
+			if err != nil {
+				t.Fatal(err)
+			}
+			bag, err := baggage.New(m, other)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(
+				sdktrace.WithSpanProcessor(newBaggageProcessor([]string{"tenant"})),
+				sdktrace.WithSpanProcessor(rec),
+			)
+			_, span := tp.Tracer("test").Start(baggage.ContextWithBaggage(context.Background(), bag), "span")
+			span.End()
+
+			attrs := rec.Ended()[0].Attributes()
+			if len(attrs) != 1 || attrs[0].Key != "tenant" || attrs[0].Value.AsString() != tt.want {
+				t.Errorf("attributes = %v, want tenant=%q only", attrs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"strings"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BatchConfig tunes the batch span processor in front of one exporter. Zero
+// fields keep the SDK defaults, which honour the OTEL_BSP_* variables.
+//
+// Every exporter gets its own processor, queue and goroutine. The queue
+// drops spans when full rather than blocking, so a slow or unreachable
+// destination only loses its own spans.
+type BatchConfig struct {
+	MaxQueueSize       int
+	MaxExportBatchSize int
+	BatchTimeout       time.Duration
+	// ExportTimeout bounds a single export so a hung destination cannot
+	// hold on to its batch forever.
+	ExportTimeout time.Duration
+}
+
+func (c BatchConfig) options() []sdktrace.BatchSpanProcessorOption {
+	var opts []sdktrace.BatchSpanProcessorOption
+	if c.MaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(c.MaxQueueSize))
+	}
+	if c.MaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(c.MaxExportBatchSize))
+	}
+	if c.BatchTimeout > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(c.BatchTimeout))
+	}
+	if c.ExportTimeout > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(c.ExportTimeout))
+	}
+	return opts
+}
+
+// multiError reports every error collected while shutting down.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
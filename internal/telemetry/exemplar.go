@@ -0,0 +1,214 @@
+package telemetry
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Histogram bucket bounds. Durations, in seconds, get the latency ones and
+// every other histogram, e.g. amounts, the value ones.
+var (
+	durationBoundaries = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	valueBoundaries    = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+)
+
+// histogramBoundaries returns the bucket bounds of histograms in u. The
+// aggregators and the exemplars must agree on them.
+func histogramBoundaries(u unit.Unit) []float64 {
+	if u == "s" {
+		return durationBoundaries
+	}
+	return valueBoundaries
+}
+
+// exemplars keeps, for each bucket of each histogram series, the trace of
+// the last sampled span that recorded a value in it. The Prometheus
+// exporter of this SDK version knows nothing of exemplars, so they are
+// added to its output by Gatherer.
+type exemplars struct {
+	// resourceLabels are the labels the exporter adds from the resource,
+	// which the recorded attributes do not have.
+	resourceLabels map[string]bool
+
+	mu     sync.Mutex
+	series map[string]*seriesExemplars
+}
+
+// seriesExemplars holds an exemplar per bucket of a series, the last one
+// for +Inf.
+type seriesExemplars struct {
+	bounds  []float64
+	buckets []*dto.Exemplar
+}
+
+func newExemplars(res *resource.Resource) *exemplars {
+	e := &exemplars{
+		resourceLabels: make(map[string]bool),
+		series:         make(map[string]*seriesExemplars),
+	}
+	for _, kv := range res.Attributes() {
+		e.resourceLabels[sanitize(string(kv.Key))] = true
+	}
+	return e
+}
+
+func (e *exemplars) record(ctx context.Context, name string, bounds []float64, v float64, attrs []attribute.KeyValue) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		return
+	}
+
+	labels := make([]*dto.LabelPair, 0, len(attrs))
+	set := attribute.NewSet(attrs...)
+	for iter := set.Iter(); iter.Next(); {
+		kv := iter.Attribute()
+		labels = append(labels, &dto.LabelPair{
+			Name:  proto.String(sanitize(string(kv.Key))),
+			Value: proto.String(kv.Value.Emit()),
+		})
+	}
+	key := seriesKey(name, labels, nil)
+
+	ex := &dto.Exemplar{
+		Label: []*dto.LabelPair{
+			{Name: proto.String("trace_id"), Value: proto.String(sc.TraceID().String())},
+			{Name: proto.String("span_id"), Value: proto.String(sc.SpanID().String())},
+		},
+		Value:     proto.Float64(v),
+		Timestamp: timestamppb.New(time.Now()),
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.series[key]
+	if !ok {
+		s = &seriesExemplars{bounds: bounds, buckets: make([]*dto.Exemplar, len(bounds)+1)}
+		e.series[key] = s
+	}
+	s.buckets[sort.SearchFloat64s(bounds, v)] = ex
+}
+
+// Gatherer returns g with the exemplars added to the buckets of its
+// histograms. The +Inf bucket is implicit in the exporter's output, so
+// values above the last boundary have none.
+func (e *exemplars) Gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		for _, mf := range mfs {
+			if mf.GetType() != dto.MetricType_HISTOGRAM {
+				continue
+			}
+			for _, m := range mf.Metric {
+				s, ok := e.series[seriesKey(mf.GetName(), m.Label, e.resourceLabels)]
+				if !ok {
+					continue
+				}
+				for _, b := range m.GetHistogram().GetBucket() {
+					i := sort.SearchFloat64s(s.bounds, b.GetUpperBound())
+					if i < len(s.bounds) && s.bounds[i] == b.GetUpperBound() {
+						b.Exemplar = s.buckets[i]
+					}
+				}
+			}
+		}
+		return mfs, err
+	})
+}
+
+// seriesKey identifies a series by its metric name and labels, leaving out
+// those in skip.
+func seriesKey(name string, labels []*dto.LabelPair, skip map[string]bool) string {
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if !skip[l.GetName()] {
+			pairs = append(pairs, l.GetName()+"="+l.GetValue())
+		}
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, "\xff") + "}"
+}
+
+// sanitize turns an OTel name into the Prometheus one the exporter uses.
+func sanitize(s string) string {
+	if s == "" {
+		return s
+	}
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+	if unicode.IsDigit(rune(s[0])) {
+		s = "key_" + s
+	}
+	if s[0] == '_' {
+		s = "key" + s
+	}
+	return s
+}
+
+// exemplarMeterProvider records an exemplar with every value of its float64
+// histograms.
+type exemplarMeterProvider struct {
+	metric.MeterProvider
+	exemplars *exemplars
+}
+
+func (p exemplarMeterProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	return exemplarMeter{p.MeterProvider.Meter(name, opts...), p.exemplars}
+}
+
+type exemplarMeter struct {
+	metric.Meter
+	exemplars *exemplars
+}
+
+func (m exemplarMeter) SyncFloat64() syncfloat64.InstrumentProvider {
+	return exemplarInstruments{m.Meter.SyncFloat64(), m.exemplars}
+}
+
+type exemplarInstruments struct {
+	syncfloat64.InstrumentProvider
+	exemplars *exemplars
+}
+
+func (p exemplarInstruments) Histogram(name string, opts ...instrument.Option) (syncfloat64.Histogram, error) {
+	h, err := p.InstrumentProvider.Histogram(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	bounds := histogramBoundaries(instrument.NewConfig(opts...).Unit())
+	return exemplarHistogram{h, sanitize(name), bounds, p.exemplars}, nil
+}
+
+type exemplarHistogram struct {
+	syncfloat64.Histogram
+	name      string
+	bounds    []float64
+	exemplars *exemplars
+}
+
+func (h exemplarHistogram) Record(ctx context.Context, v float64, attrs ...attribute.KeyValue) {
+	h.Histogram.Record(ctx, v, attrs...)
+	h.exemplars.record(ctx, h.name, h.bounds, v, attrs)
+}
@@ -0,0 +1,149 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Span exporters accepted in OTEL_TRACES_EXPORTER and Config.Exporter.
+const (
+	ExporterJaegerAgent     = "jaeger-agent"
+	ExporterJaegerCollector = "jaeger-collector"
+	ExporterOTLPGRPC        = "otlp-grpc"
+	ExporterOTLPHTTP        = "otlp-http"
+	ExporterStdout          = "stdout"
+	ExporterFile            = "file"
+	ExporterNone            = "none"
+)
+
+// exporterNames returns the exporters chosen by the comma separated
+// OTEL_TRACES_EXPORTER, falling back to the service default and then to the
+// Jaeger agent. The spec names "jaeger" and "otlp" are accepted as aliases
+// and "none" disables exporting.
+func exporterNames(cfg Config) []string {
+	list := os.Getenv("OTEL_TRACES_EXPORTER")
+	if strings.TrimSpace(list) == "" {
+		list = cfg.Exporter
+	}
+	if strings.TrimSpace(list) == "" {
+		list = ExporterJaegerAgent
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "", ExporterNone:
+			continue
+		case "jaeger":
+			name = ExporterJaegerAgent
+		case "otlp":
+			name = ExporterOTLPGRPC
+			if otlpProtocol() == "http/protobuf" {
+				name = ExporterOTLPHTTP
+			}
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// otlpProtocol returns the OTLP transport from the environment, preferring
+// the traces specific variable.
+func otlpProtocol() string {
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); p != "" {
+		return p
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+}
+
+// NewExporters returns the span exporters selected for cfg, without the
+// batching and tracer provider Setup puts in front of them. It is meant for
+// tools that export already finished spans.
+func NewExporters(ctx context.Context, cfg Config) ([]sdktrace.SpanExporter, error) {
+	named, err := newExporters(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	exps := make([]sdktrace.SpanExporter, len(named))
+	for i, exp := range named {
+		exps[i] = exp
+	}
+	return exps, nil
+}
+
+func newExporters(ctx context.Context, cfg Config) ([]namedExporter, error) {
+	var exps []namedExporter
+	for _, name := range exporterNames(cfg) {
+		exp, err := newExporter(ctx, cfg, name)
+		if err != nil {
+			for _, exp := range exps {
+				_ = exp.Shutdown(ctx)
+			}
+			return nil, err
+		}
+		exps = append(exps, namedExporter{name: name, SpanExporter: exp})
+	}
+	return exps, nil
+}
+
+// newExporter returns the span exporter called name.
+func newExporter(ctx context.Context, cfg Config, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterJaegerAgent:
+		return newJaegerExporter(cfg.Jaeger, false)
+	case ExporterJaegerCollector:
+		return newJaegerExporter(cfg.Jaeger, true)
+	case ExporterOTLPGRPC:
+		return newOTLPGRPCExporter(ctx, cfg.OTLP)
+	case ExporterOTLPHTTP:
+		return newOTLPHTTPExporter(ctx, cfg.OTLP)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterFile:
+		return newFileExporter(cfg.File)
+	default:
+		return nil, fmt.Errorf("telemetry: unknown span exporter %q", name)
+	}
+}
+
+// namedExporter prefixes errors with the exporter name so that failures of
+// one destination can be told apart in the logs.
+type namedExporter struct {
+	name string
+	sdktrace.SpanExporter
+}
+
+func (e namedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if err := e.SpanExporter.ExportSpans(ctx, spans); err != nil {
+		return fmt.Errorf("%s exporter: %w", e.name, err)
+	}
+	return nil
+}
+
+func (e namedExporter) Shutdown(ctx context.Context) error {
+	if err := e.SpanExporter.Shutdown(ctx); err != nil {
+		return fmt.Errorf("%s exporter: %w", e.name, err)
+	}
+	return nil
+}
+
+// envOr returns the value of the environment variable key, or def when it is
+// unset or empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,67 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"opentelemetry/internal/tracefile"
+)
+
+// File exporter defaults, used when neither the config nor the environment
+// set a value.
+const (
+	defaultTraceFile      = "traces.txt"
+	defaultFileMaxSize    = 10 << 20
+	defaultFileMaxAge     = 24 * time.Hour
+	defaultFileMaxBackups = 7
+)
+
+// fileConfigWithEnv fills the empty fields of c from the
+// OTEL_EXPORTER_FILE_PATH, _MAX_SIZE (bytes), _MAX_AGE (a Go duration),
+// _MAX_BACKUPS and _COMPRESS variables.
+func fileConfigWithEnv(c tracefile.Config) (tracefile.Config, error) {
+	if c.Path == "" {
+		c.Path = envOr("OTEL_EXPORTER_FILE_PATH", defaultTraceFile)
+	}
+	if c.MaxSize == 0 {
+		n, err := strconv.ParseInt(envOr("OTEL_EXPORTER_FILE_MAX_SIZE", strconv.Itoa(defaultFileMaxSize)), 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("telemetry: OTEL_EXPORTER_FILE_MAX_SIZE: %w", err)
+		}
+		c.MaxSize = n
+	}
+	if c.MaxAge == 0 {
+		d, err := time.ParseDuration(envOr("OTEL_EXPORTER_FILE_MAX_AGE", defaultFileMaxAge.String()))
+		if err != nil {
+			return c, fmt.Errorf("telemetry: OTEL_EXPORTER_FILE_MAX_AGE: %w", err)
+		}
+		c.MaxAge = d
+	}
+	if c.MaxBackups == 0 {
+		n, err := strconv.Atoi(envOr("OTEL_EXPORTER_FILE_MAX_BACKUPS", strconv.Itoa(defaultFileMaxBackups)))
+		if err != nil {
+			return c, fmt.Errorf("telemetry: OTEL_EXPORTER_FILE_MAX_BACKUPS: %w", err)
+		}
+		c.MaxBackups = n
+	}
+	if !c.Compress {
+		if v := os.Getenv("OTEL_EXPORTER_FILE_COMPRESS"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return c, fmt.Errorf("telemetry: OTEL_EXPORTER_FILE_COMPRESS: %w", err)
+			}
+			c.Compress = b
+		}
+	}
+	return c, nil
+}
+
+func newFileExporter(c tracefile.Config) (*tracefile.Exporter, error) {
+	c, err := fileConfigWithEnv(c)
+	if err != nil {
+		return nil, err
+	}
+	return tracefile.NewExporter(c)
+}
@@ -0,0 +1,125 @@
+package telemetry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// maxUDPPacketSize is the largest payload a single UDP datagram can carry.
+const maxUDPPacketSize = 65507
+
+// JaegerConfig configures the Jaeger span exporter. Agent fields are used by
+// ExporterJaegerAgent and collector fields by ExporterJaegerCollector.
+//
+// Empty fields are filled from the OTEL_EXPORTER_JAEGER_* variables and then
+// from Jaeger's defaults; the process environment is never modified.
+type JaegerConfig struct {
+	// AgentHost and AgentPort address the UDP agent, localhost:6831 by
+	// default.
+	AgentHost string
+	AgentPort string
+	// MaxPacketSize caps the size of each UDP packet sent to the agent.
+	// Zero uses the exporter default of 65000 bytes.
+	MaxPacketSize int
+
+	// Endpoint is the collector's HTTP Thrift URL,
+	// http://localhost:14268/api/traces by default.
+	Endpoint string
+	// Username and Password enable basic auth against the collector.
+	Username string
+	Password string
+	// HTTPClient sends requests to the collector, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// withEnv returns a copy of c with empty fields taken from the environment
+// or defaults.
+func (c JaegerConfig) withEnv() JaegerConfig {
+	if c.AgentHost == "" {
+		c.AgentHost = envOr("OTEL_EXPORTER_JAEGER_AGENT_HOST", "localhost")
+	}
+	if c.AgentPort == "" {
+		c.AgentPort = envOr("OTEL_EXPORTER_JAEGER_AGENT_PORT", "6831")
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = envOr("OTEL_EXPORTER_JAEGER_ENDPOINT", "http://localhost:14268/api/traces")
+	}
+	if c.Username == "" {
+		c.Username = envOr("OTEL_EXPORTER_JAEGER_USER", "")
+	}
+	if c.Password == "" {
+		c.Password = envOr("OTEL_EXPORTER_JAEGER_PASSWORD", "")
+	}
+	return c
+}
+
+func (c JaegerConfig) validateAgent() error {
+	if c.AgentHost == "" {
+		return errors.New("telemetry: jaeger agent host is empty")
+	}
+	if port, err := strconv.Atoi(c.AgentPort); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("telemetry: jaeger agent port %q is not a valid port number", c.AgentPort)
+	}
+	if c.MaxPacketSize < 0 || c.MaxPacketSize > maxUDPPacketSize {
+		return fmt.Errorf("telemetry: jaeger max packet size %d is outside 0..%d", c.MaxPacketSize, maxUDPPacketSize)
+	}
+	return nil
+}
+
+func (c JaegerConfig) validateCollector() error {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return fmt.Errorf("telemetry: jaeger collector endpoint %q: %w", c.Endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("telemetry: jaeger collector endpoint %q must be an http or https URL", c.Endpoint)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("telemetry: jaeger collector endpoint %q has no host", c.Endpoint)
+	}
+	if c.Password != "" && c.Username == "" {
+		return errors.New("telemetry: jaeger collector password is set without a username")
+	}
+	return nil
+}
+
+// newJaegerExporter validates c and returns an exporter sending to the
+// collector when collector is true, or to the agent otherwise.
+func newJaegerExporter(c JaegerConfig, collector bool) (sdktrace.SpanExporter, error) {
+	c = c.withEnv()
+
+	if collector {
+		if err := c.validateCollector(); err != nil {
+			return nil, err
+		}
+
+		opts := []jaeger.CollectorEndpointOption{
+			jaeger.WithEndpoint(c.Endpoint),
+			jaeger.WithUsername(c.Username),
+			jaeger.WithPassword(c.Password),
+		}
+		if c.HTTPClient != nil {
+			opts = append(opts, jaeger.WithHTTPClient(c.HTTPClient))
+		}
+		return jaeger.New(jaeger.WithCollectorEndpoint(opts...))
+	}
+
+	if err := c.validateAgent(); err != nil {
+		return nil, err
+	}
+
+	opts := []jaeger.AgentEndpointOption{
+		jaeger.WithAgentHost(c.AgentHost),
+		jaeger.WithAgentPort(c.AgentPort),
+	}
+	if c.MaxPacketSize > 0 {
+		opts = append(opts, jaeger.WithMaxPacketSize(c.MaxPacketSize))
+	}
+	return jaeger.New(jaeger.WithAgentEndpoint(opts...))
+}
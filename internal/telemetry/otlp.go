@@ -0,0 +1,344 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// OTLP defaults from the exporter specification.
+const (
+	defaultOTLPGRPCEndpoint = "localhost:4317"
+	defaultOTLPHTTPEndpoint = "localhost:4318"
+	defaultOTLPTracesPath   = "/v1/traces"
+)
+
+// OTLPConfig configures the otlp-grpc and otlp-http exporters.
+//
+// Empty fields are filled from the OTEL_EXPORTER_OTLP_TRACES_* variables,
+// then from OTEL_EXPORTER_OTLP_*, then from the exporter defaults.
+type OTLPConfig struct {
+	// Endpoint is either host:port or a URL. An http:// URL turns off TLS
+	// and, for otlp-http, a URL path replaces the default /v1/traces.
+	Endpoint string
+	// Insecure turns off TLS.
+	Insecure bool
+
+	// CAFile is a PEM bundle used instead of the system roots to verify the
+	// collector. ClientCertFile and ClientKeyFile enable mutual TLS.
+	CAFile         string
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// Headers are sent with every export, e.g. for auth tokens.
+	Headers map[string]string
+	// Compression is "gzip" or "none".
+	Compression string
+	// Timeout bounds each export, including retries.
+	Timeout time.Duration
+	Retry   RetryConfig
+}
+
+// RetryConfig controls how failed OTLP exports are retried. The zero value
+// keeps the exporter defaults: retries enabled, starting at 5s, backing off
+// up to 30s and giving up after a minute.
+type RetryConfig struct {
+	Disabled        bool
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	MaxElapsedTime  time.Duration
+}
+
+func (r RetryConfig) isZero() bool {
+	return r == RetryConfig{}
+}
+
+func (r RetryConfig) withDefaults() RetryConfig {
+	if r.InitialInterval == 0 {
+		r.InitialInterval = 5 * time.Second
+	}
+	if r.MaxInterval == 0 {
+		r.MaxInterval = 30 * time.Second
+	}
+	if r.MaxElapsedTime == 0 {
+		r.MaxElapsedTime = time.Minute
+	}
+	return r
+}
+
+// otlpEnv returns the traces specific variable for name, falling back to the
+// generic one. generic reports whether the fallback was used.
+func otlpEnv(name string) (v string, generic bool) {
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_" + name); v != "" {
+		return v, false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_" + name), true
+}
+
+// withEnv returns a copy of c with empty fields taken from the environment.
+func (c OTLPConfig) withEnv() (OTLPConfig, error) {
+	if c.Endpoint == "" {
+		v, generic := otlpEnv("ENDPOINT")
+		// The generic endpoint is a base URL the signal path is appended
+		// to, while the traces endpoint is used as is.
+		if generic && strings.Contains(v, "://") {
+			u, err := url.Parse(v)
+			if err != nil {
+				return c, fmt.Errorf("telemetry: OTEL_EXPORTER_OTLP_ENDPOINT %q: %w", v, err)
+			}
+			u.Path = path.Join(u.Path, defaultOTLPTracesPath)
+			v = u.String()
+		}
+		c.Endpoint = v
+	}
+	if !c.Insecure {
+		if v, _ := otlpEnv("INSECURE"); v != "" {
+			insecure, err := strconv.ParseBool(v)
+			if err != nil {
+				return c, fmt.Errorf("telemetry: OTLP insecure flag %q: %w", v, err)
+			}
+			c.Insecure = insecure
+		}
+	}
+	if c.CAFile == "" {
+		c.CAFile, _ = otlpEnv("CERTIFICATE")
+	}
+	if c.ClientCertFile == "" {
+		c.ClientCertFile, _ = otlpEnv("CLIENT_CERTIFICATE")
+	}
+	if c.ClientKeyFile == "" {
+		c.ClientKeyFile, _ = otlpEnv("CLIENT_KEY")
+	}
+	if c.Headers == nil {
+		if v, _ := otlpEnv("HEADERS"); v != "" {
+			h, err := parseHeaders(v)
+			if err != nil {
+				return c, err
+			}
+			c.Headers = h
+		}
+	}
+	if c.Compression == "" {
+		c.Compression, _ = otlpEnv("COMPRESSION")
+	}
+	if c.Timeout == 0 {
+		if v, _ := otlpEnv("TIMEOUT"); v != "" {
+			ms, err := strconv.Atoi(v)
+			if err != nil {
+				return c, fmt.Errorf("telemetry: OTLP timeout %q is not a number of milliseconds", v)
+			}
+			c.Timeout = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return c, nil
+}
+
+// parseHeaders parses the "key1=value1,key2=value2" format of
+// OTEL_EXPORTER_OTLP_HEADERS. Values may be URL encoded.
+func parseHeaders(s string) (map[string]string, error) {
+	h := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("telemetry: OTLP header %q is not in key=value form", pair)
+		}
+
+		v, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: OTLP header %q: %w", k, err)
+		}
+		h[k] = v
+	}
+	return h, nil
+}
+
+// target splits the endpoint into host:port and URL path, applying the
+// default endpoint and turning off TLS for http:// URLs.
+func (c OTLPConfig) target(defaultEndpoint string) (host, urlPath string, insecure bool, err error) {
+	if c.Endpoint == "" {
+		return defaultEndpoint, defaultOTLPTracesPath, c.Insecure, nil
+	}
+	if !strings.Contains(c.Endpoint, "://") {
+		return c.Endpoint, defaultOTLPTracesPath, c.Insecure, nil
+	}
+
+	u, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return "", "", false, fmt.Errorf("telemetry: OTLP endpoint %q: %w", c.Endpoint, err)
+	}
+	switch u.Scheme {
+	case "http":
+		insecure = true
+	case "https":
+		insecure = c.Insecure
+	default:
+		return "", "", false, fmt.Errorf("telemetry: OTLP endpoint %q must use http or https", c.Endpoint)
+	}
+	if u.Host == "" {
+		return "", "", false, fmt.Errorf("telemetry: OTLP endpoint %q has no host", c.Endpoint)
+	}
+
+	urlPath = u.Path
+	if urlPath == "" {
+		urlPath = defaultOTLPTracesPath
+	}
+	return u.Host, urlPath, insecure, nil
+}
+
+func (c OTLPConfig) validate(insecure bool) error {
+	switch c.Compression {
+	case "", "none", "gzip":
+	default:
+		return fmt.Errorf("telemetry: OTLP compression %q is not one of gzip or none", c.Compression)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("telemetry: OTLP timeout %s is negative", c.Timeout)
+	}
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		return errors.New("telemetry: OTLP client certificate and key must be set together")
+	}
+	if insecure && (c.CAFile != "" || c.ClientCertFile != "") {
+		return errors.New("telemetry: OTLP TLS certificates are set but the endpoint is insecure")
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS settings for the configured certificates, or
+// nil when the system defaults should be used.
+func (c OTLPConfig) tlsConfig() (*tls.Config, error) {
+	if c.CAFile == "" && c.ClientCertFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: reading OTLP CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("telemetry: OTLP CA file %s contains no PEM certificates", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: loading OTLP client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func newOTLPGRPCExporter(ctx context.Context, c OTLPConfig) (sdktrace.SpanExporter, error) {
+	c, err := c.withEnv()
+	if err != nil {
+		return nil, err
+	}
+	host, _, insecure, err := c.target(defaultOTLPGRPCEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.validate(insecure); err != nil {
+		return nil, err
+	}
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(host)}
+	if insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else if tlsCfg != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(c.Headers))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlptracegrpc.WithTimeout(c.Timeout))
+	}
+	if !c.Retry.isZero() {
+		r := c.Retry.withDefaults()
+		opts = append(opts, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         !r.Disabled,
+			InitialInterval: r.InitialInterval,
+			MaxInterval:     r.MaxInterval,
+			MaxElapsedTime:  r.MaxElapsedTime,
+		}))
+	}
+
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+func newOTLPHTTPExporter(ctx context.Context, c OTLPConfig) (sdktrace.SpanExporter, error) {
+	c, err := c.withEnv()
+	if err != nil {
+		return nil, err
+	}
+	host, urlPath, insecure, err := c.target(defaultOTLPHTTPEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.validate(insecure); err != nil {
+		return nil, err
+	}
+	tlsCfg, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(host),
+		otlptracehttp.WithURLPath(urlPath),
+	}
+	if insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else if tlsCfg != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsCfg))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(c.Headers))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlptracehttp.WithTimeout(c.Timeout))
+	}
+	if !c.Retry.isZero() {
+		r := c.Retry.withDefaults()
+		opts = append(opts, otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         !r.Disabled,
+			InitialInterval: r.InitialInterval,
+			MaxInterval:     r.MaxInterval,
+			MaxElapsedTime:  r.MaxElapsedTime,
+		}))
+	}
+
+	return otlptracehttp.New(ctx, opts...)
+}
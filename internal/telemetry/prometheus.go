@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator"
+	"go.opentelemetry.io/otel/sdk/metric/aggregator/histogram"
+	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	processor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// newPrometheusController returns a meter provider whose metrics are
+// collected by reg on every scrape, labelled with res.
+func newPrometheusController(res *resource.Resource, reg prometheus.Registerer) (*controller.Controller, error) {
+	ctrl := controller.New(
+		processor.NewFactory(
+			histogramSelector{simple.NewWithHistogramDistribution()},
+			aggregation.CumulativeTemporalitySelector(),
+			processor.WithMemory(true),
+		),
+		controller.WithResource(res),
+	)
+	if _, err := otelprom.New(otelprom.Config{Registerer: reg}, ctrl); err != nil {
+		return nil, fmt.Errorf("telemetry: prometheus exporter: %w", err)
+	}
+	return ctrl, nil
+}
+
+// histogramSelector aggregates histograms into the buckets their unit
+// calls for, see histogramBoundaries, and everything else as next does.
+type histogramSelector struct {
+	next export.AggregatorSelector
+}
+
+func (s histogramSelector) AggregatorFor(desc *sdkapi.Descriptor, aggPtrs ...*aggregator.Aggregator) {
+	if desc.InstrumentKind() != sdkapi.HistogramInstrumentKind {
+		s.next.AggregatorFor(desc, aggPtrs...)
+		return
+	}
+	aggs := histogram.New(len(aggPtrs), desc, histogram.WithExplicitBoundaries(histogramBoundaries(desc.Unit())))
+	for i := range aggPtrs {
+		*aggPtrs[i] = &aggs[i]
+	}
+}
+
+// NewPrometheusBridge returns a meter provider whose instruments are
+// collected by reg, under their own names and attributes and with no
+// resource labels. It lets metrics move from client_golang to the OTel API
+// without changing the series dashboards query. Instruments named like
+// Prometheus metrics, e.g. "jobs_done_total", keep that exact name.
+func NewPrometheusBridge(reg prometheus.Registerer) (metric.MeterProvider, error) {
+	return newPrometheusController(resource.Empty(), reg)
+}
+
+// newMetricsHandler serves the metrics of g, in OpenMetrics when the
+// scraper asks for it so that exemplars reach Prometheus.
+func newMetricsHandler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(counterTotals(g), promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// counterTotals returns g with "_total" added to the names of its counters.
+// OpenMetrics requires the suffix and client_golang writes counters without
+// it as of type unknown, so they would lose their type on the scrapes that
+// carry exemplars. The text format gets the same names, so that series are
+// named alike whichever format Prometheus picks.
+func counterTotals(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		for _, mf := range mfs {
+			if mf.GetType() == dto.MetricType_COUNTER && !strings.HasSuffix(mf.GetName(), "_total") {
+				name := mf.GetName() + "_total"
+				mf.Name = &name
+			}
+		}
+		return mfs, err
+	})
+}
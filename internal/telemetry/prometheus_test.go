@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// prometheusAccept is the Accept header Prometheus scrapes with.
+const prometheusAccept = "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"
+
+func TestMetricsHandler(t *testing.T) {
+	res := resource.NewSchemaless(attribute.String("service.name", "payments"))
+	reg := prometheus.NewRegistry()
+	ctrl, err := newPrometheusController(res, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := newExemplars(res)
+	meter := exemplarMeterProvider{ctrl, ex}.Meter("test")
+
+	requests, err := meter.SyncInt64().Counter("http.server.requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := meter.SyncInt64().Counter("jobs_done_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	duration, err := meter.SyncFloat64().Histogram("http.server.duration", instrument.WithUnit(unit.Unit("s")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	requests.Add(ctx, 1, attribute.String("http.route", "/api/payment"))
+	done.Add(ctx, 1)
+	duration.Record(ctx, 0.2, attribute.String("http.route", "/api/payment"))
+
+	h := newMetricsHandler(ex.Gatherer(reg))
+
+	tests := []struct {
+		name     string
+		accept   string
+		want     []string
+		dontWant []string
+	}{
+		{
+			name:   "openmetrics",
+			accept: prometheusAccept,
+			want: []string{
+				"# TYPE http_server_requests counter",
+				`http_server_requests_total{http_route="/api/payment",service_name="payments"} 1.0`,
+				"# TYPE jobs_done counter",
+				`jobs_done_total{service_name="payments"} 1.0`,
+				`le="0.25"} 1 # {trace_id="01000000000000000000000000000000",span_id="0100000000000000"} 0.2`,
+				"# EOF",
+			},
+			dontWant: []string{"unknown", "_total_total"},
+		},
+		{
+			name:   "text",
+			accept: "text/plain",
+			want: []string{
+				"# TYPE http_server_requests_total counter",
+				`http_server_requests_total{http_route="/api/payment",service_name="payments"} 1`,
+				"# TYPE jobs_done_total counter",
+			},
+			dontWant: []string{"untyped", "_total_total"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			b, _ := io.ReadAll(rec.Body)
+			body := string(b)
+			for _, w := range tt.want {
+				if !strings.Contains(body, w) {
+					t.Errorf("missing %q in:\n%s", w, body)
+				}
+			}
+			for _, w := range tt.dontWant {
+				if strings.Contains(body, w) {
+					t.Errorf("unexpected %q in:\n%s", w, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/contrib/propagators/ot"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Propagators accepted in OTEL_PROPAGATORS and Config.Propagators.
+const (
+	PropagatorTraceContext = "tracecontext"
+	PropagatorBaggage      = "baggage"
+	PropagatorB3           = "b3"
+	PropagatorB3Multi      = "b3multi"
+	PropagatorJaeger       = "jaeger"
+	PropagatorOTTrace      = "ottrace"
+	PropagatorNone         = "none"
+)
+
+// newPropagator returns the composite of the propagators listed in the comma
+// separated OTEL_PROPAGATORS, falling back to cfg.Propagators and then to
+// tracecontext and baggage. Incoming requests are extracted with every
+// format in turn and outgoing ones carry the headers of all of them.
+func newPropagator(cfg Config) (propagation.TextMapPropagator, error) {
+	list := os.Getenv("OTEL_PROPAGATORS")
+	if strings.TrimSpace(list) == "" {
+		list = cfg.Propagators
+	}
+	if strings.TrimSpace(list) == "" {
+		list = PropagatorTraceContext + "," + PropagatorBaggage
+	}
+
+	var props []propagation.TextMapPropagator
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case PropagatorTraceContext:
+			props = append(props, propagation.TraceContext{})
+		case PropagatorBaggage:
+			props = append(props, propagation.Baggage{})
+		case PropagatorB3:
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			props = append(props, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			props = append(props, jaeger.Jaeger{})
+		case PropagatorOTTrace:
+			props = append(props, ot.OT{})
+		case PropagatorNone:
+		default:
+			return nil, fmt.Errorf("telemetry: unknown propagator %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(props...), nil
+}
@@ -0,0 +1,183 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+// Build attributes taken from debug.ReadBuildInfo. Semantic conventions
+// have no keys for them yet.
+const (
+	vcsRevisionKey = attribute.Key("vcs.revision")
+	vcsTimeKey     = attribute.Key("vcs.time")
+	vcsModifiedKey = attribute.Key("vcs.modified")
+)
+
+// k8sEnv maps the variables a pod spec is expected to fill through the
+// downward API to their resource attributes.
+var k8sEnv = map[string]attribute.Key{
+	"K8S_CLUSTER_NAME":    semconv.K8SClusterNameKey,
+	"K8S_NAMESPACE_NAME":  semconv.K8SNamespaceNameKey,
+	"K8S_NODE_NAME":       semconv.K8SNodeNameKey,
+	"K8S_POD_NAME":        semconv.K8SPodNameKey,
+	"K8S_POD_UID":         semconv.K8SPodUIDKey,
+	"K8S_CONTAINER_NAME":  semconv.K8SContainerNameKey,
+	"K8S_DEPLOYMENT_NAME": semconv.K8SDeploymentNameKey,
+}
+
+// newResource detects the resource describing this process. From lowest to
+// highest precedence it merges the SDK, host, OS, process, runtime,
+// container, Kubernetes and build information, the service described by cfg,
+// and finally OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME.
+func newResource(ctx context.Context, cfg Config) (*resource.Resource, error) {
+	instanceID, err := newInstanceID()
+	if err != nil {
+		return nil, fmt.Errorf("telemetry: generating service instance id: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(cfg.ServiceName),
+		semconv.ServiceInstanceIDKey.String(instanceID),
+	}
+	version := cfg.ServiceVersion
+	if version == "" {
+		version = buildVersion()
+	}
+	if version != "" {
+		attrs = append(attrs, semconv.ServiceVersionKey.String(version))
+	}
+	if cfg.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironmentKey.String(cfg.Environment))
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOSType(),
+		resource.WithProcess(),
+		resource.WithContainer(),
+		resource.WithDetectors(k8sDetector{}, buildDetector{}),
+		resource.WithAttributes(attrs...),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		// A detector that fails, e.g. outside a container, still leaves
+		// everything else in place.
+		otel.Handle(err)
+	}
+	if res == nil {
+		res = resource.NewWithAttributes(semconv.SchemaURL, attrs...)
+	}
+	return res, nil
+}
+
+// metricResourceKeys are the resource attributes kept on metrics. The
+// Prometheus exporter labels every series with the whole resource, and the
+// host and process attributes, the instance ID first, would only multiply
+// series that the scrape target already identifies.
+var metricResourceKeys = []attribute.Key{
+	semconv.ServiceNameKey,
+	semconv.ServiceNamespaceKey,
+	semconv.ServiceVersionKey,
+	semconv.DeploymentEnvironmentKey,
+}
+
+// metricResource returns the part of res that describes the service.
+func metricResource(res *resource.Resource) *resource.Resource {
+	var attrs []attribute.KeyValue
+	for _, k := range metricResourceKeys {
+		if v, ok := res.Set().Value(k); ok {
+			attrs = append(attrs, k.String(v.Emit()))
+		}
+	}
+	return resource.NewWithAttributes(res.SchemaURL(), attrs...)
+}
+
+// k8sDetector reads pod metadata exposed through the downward API.
+type k8sDetector struct{}
+
+func (k8sDetector) Detect(context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	for env, key := range k8sEnv {
+		if v := os.Getenv(env); v != "" {
+			attrs = append(attrs, key.String(v))
+		}
+	}
+	if len(attrs) == 0 {
+		return resource.Empty(), nil
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// buildDetector records the VCS revision the binary was built from.
+type buildDetector struct{}
+
+func (buildDetector) Detect(context.Context) (*resource.Resource, error) {
+	settings := buildSettings()
+
+	var attrs []attribute.KeyValue
+	if v := settings["vcs.revision"]; v != "" {
+		attrs = append(attrs, vcsRevisionKey.String(v))
+	}
+	if v := settings["vcs.time"]; v != "" {
+		attrs = append(attrs, vcsTimeKey.String(v))
+	}
+	if v := settings["vcs.modified"]; v != "" {
+		attrs = append(attrs, vcsModifiedKey.Bool(v == "true"))
+	}
+	if len(attrs) == 0 {
+		return resource.Empty(), nil
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+func buildSettings() map[string]string {
+	settings := make(map[string]string)
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return settings
+	}
+	for _, s := range bi.Settings {
+		settings[s.Key] = s.Value
+	}
+	settings["main.version"] = bi.Main.Version
+	return settings
+}
+
+// buildVersion returns the module version of the binary, or its short VCS
+// revision for development builds.
+func buildVersion() string {
+	settings := buildSettings()
+	if v := settings["main.version"]; v != "" && v != "(devel)" {
+		return v
+	}
+
+	rev := settings["vcs.revision"]
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	if rev != "" && settings["vcs.modified"] == "true" {
+		rev += "-dirty"
+	}
+	return rev
+}
+
+// newInstanceID returns a random UUID (version 4) identifying this process.
+func newInstanceID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,245 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+// Samplers accepted in OTEL_TRACES_SAMPLER and Config.Sampler, those of the
+// specification. Sampling rules take the place of the ratio ones.
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+// SamplingRuleKey records on each sampled span the name of the rule that
+// sampled it.
+const SamplingRuleKey = attribute.Key("sampling.rule")
+
+// SamplingRule samples spans matching all of its non-empty conditions at
+// Ratio. Rules only see what is known when a span starts, so outcomes such
+// as errors have to be kept by tail sampling instead.
+type SamplingRule struct {
+	// Name is recorded in SamplingRuleKey.
+	Name string
+	// SpanName matches the span name exactly, or by prefix when it ends
+	// in "*".
+	SpanName string
+	// Method matches http.method.
+	Method string
+	// Route matches http.route, or http.target when no route is known yet,
+	// exactly or by prefix when it ends in "*".
+	Route string
+	// Ratio is the fraction of matching traces to sample, from 0 to 1.
+	Ratio float64
+}
+
+func (r SamplingRule) matches(p sdktrace.SamplingParameters) bool {
+	if r.SpanName != "" && !matchPattern(r.SpanName, p.Name) {
+		return false
+	}
+	if r.Method == "" && r.Route == "" {
+		return true
+	}
+
+	var method, route, target string
+	for _, kv := range p.Attributes {
+		switch kv.Key {
+		case semconv.HTTPMethodKey:
+			method = kv.Value.AsString()
+		case semconv.HTTPRouteKey:
+			route = kv.Value.AsString()
+		case semconv.HTTPTargetKey:
+			target = kv.Value.AsString()
+		}
+	}
+	if route == "" {
+		route, _, _ = strings.Cut(target, "?")
+	}
+
+	if r.Method != "" && !strings.EqualFold(r.Method, method) {
+		return false
+	}
+	return r.Route == "" || matchPattern(r.Route, route)
+}
+
+func matchPattern(pattern, s string) bool {
+	if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+		return strings.HasPrefix(s, prefix)
+	}
+	return pattern == s
+}
+
+// ruleSampler samples with the first matching rule, or the fallback ratio
+// when none match.
+type ruleSampler struct {
+	rules    []SamplingRule
+	samplers []sdktrace.Sampler
+	fallback sdktrace.Sampler
+}
+
+func newRuleSampler(rules []SamplingRule, fallback float64) sdktrace.Sampler {
+	s := &ruleSampler{
+		rules:    rules,
+		fallback: sdktrace.TraceIDRatioBased(fallback),
+	}
+	for _, r := range rules {
+		s.samplers = append(s.samplers, sdktrace.TraceIDRatioBased(r.Ratio))
+	}
+	return s
+}
+
+func (s *ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	for i, r := range s.rules {
+		if !r.matches(p) {
+			continue
+		}
+
+		res := s.samplers[i].ShouldSample(p)
+		if res.Decision == sdktrace.RecordAndSample {
+			res.Attributes = append(res.Attributes, SamplingRuleKey.String(r.Name))
+		}
+		return res
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *ruleSampler) Description() string {
+	names := make([]string, len(s.rules))
+	for i, r := range s.rules {
+		names[i] = fmt.Sprintf("%s:%g", r.Name, r.Ratio)
+	}
+	return fmt.Sprintf("RuleSampler{%s,fallback:%s}", strings.Join(names, ","), s.fallback.Description())
+}
+
+// newSampler returns the sampler chosen by OTEL_TRACES_SAMPLER and
+// OTEL_TRACES_SAMPLER_ARG, falling back to cfg.Sampler and then to
+// parentbased_always_on. The argument is the ratio of the ratio samplers.
+//
+// Rules come from OTEL_TRACES_SAMPLER_RULES when set, or cfg.SamplingRules;
+// see parseSamplingRules for the format. With rules the ratio samplers
+// sample by the first matching rule, and by the ratio only the spans no
+// rule matches, and the default sampler is parentbased_traceidratio. The
+// other samplers ignore the rules. OTEL_TRACES_SAMPLER itself only takes
+// the specification's names, which the SDK also reads.
+func newSampler(cfg Config) (sdktrace.Sampler, error) {
+	rules := cfg.SamplingRules
+	if v := os.Getenv("OTEL_TRACES_SAMPLER_RULES"); v != "" {
+		r, err := parseSamplingRules(v)
+		if err != nil {
+			return nil, err
+		}
+		rules = r
+	}
+
+	name := envOr("OTEL_TRACES_SAMPLER", cfg.Sampler)
+	if name == "" {
+		name = SamplerParentBasedAlwaysOn
+		if len(rules) > 0 {
+			name = SamplerParentBasedTraceIDRatio
+		}
+	}
+
+	arg := os.Getenv("OTEL_TRACES_SAMPLER_ARG")
+	if arg == "" {
+		arg = cfg.SamplerArg
+	}
+	ratio := 1.0
+	if arg != "" {
+		r, err := parseRatio(arg)
+		if err != nil {
+			return nil, fmt.Errorf("telemetry: OTEL_TRACES_SAMPLER_ARG: %w", err)
+		}
+		ratio = r
+	}
+
+	ratioSampler := sdktrace.TraceIDRatioBased(ratio)
+	if len(rules) > 0 {
+		ratioSampler = newRuleSampler(rules, ratio)
+	}
+
+	switch name {
+	case SamplerAlwaysOn:
+		return sdktrace.AlwaysSample(), nil
+	case SamplerAlwaysOff:
+		return sdktrace.NeverSample(), nil
+	case SamplerTraceIDRatio:
+		return ratioSampler, nil
+	case SamplerParentBasedAlwaysOn:
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case SamplerParentBasedAlwaysOff:
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case SamplerParentBasedTraceIDRatio:
+		return sdktrace.ParentBased(ratioSampler), nil
+	default:
+		return nil, fmt.Errorf("telemetry: unknown sampler %q", name)
+	}
+}
+
+func parseRatio(s string) (float64, error) {
+	r, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ratio %q is not a number", s)
+	}
+	if r < 0 || r > 1 {
+		return 0, fmt.Errorf("ratio %g is outside 0..1", r)
+	}
+	return r, nil
+}
+
+// parseSamplingRules parses rules separated by ";", each a "," separated
+// list of name, span, method, route and ratio settings, e.g.
+//
+//	name=payments,route=/api/payment,ratio=1;name=fraud-get,method=GET,route=/api/fraud,ratio=0.05
+//
+// Rules without a name are named after their position.
+func parseSamplingRules(s string) ([]SamplingRule, error) {
+	var rules []SamplingRule
+	for i, spec := range strings.Split(s, ";") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		r := SamplingRule{Name: "rule-" + strconv.Itoa(i), Ratio: -1}
+		for _, setting := range strings.Split(spec, ",") {
+			k, v, ok := strings.Cut(setting, "=")
+			if !ok {
+				return nil, fmt.Errorf("telemetry: sampling rule %q: %q is not key=value", spec, setting)
+			}
+
+			switch v = strings.TrimSpace(v); strings.TrimSpace(k) {
+			case "name":
+				r.Name = v
+			case "span":
+				r.SpanName = v
+			case "method":
+				r.Method = v
+			case "route":
+				r.Route = v
+			case "ratio":
+				ratio, err := parseRatio(v)
+				if err != nil {
+					return nil, fmt.Errorf("telemetry: sampling rule %q: %w", spec, err)
+				}
+				r.Ratio = ratio
+			default:
+				return nil, fmt.Errorf("telemetry: sampling rule %q: unknown setting %q", spec, k)
+			}
+		}
+		if r.Ratio < 0 {
+			return nil, fmt.Errorf("telemetry: sampling rule %q has no ratio", spec)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
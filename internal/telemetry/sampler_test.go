@@ -0,0 +1,183 @@
+package telemetry
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRuleSampler(t *testing.T) {
+	s := newRuleSampler([]SamplingRule{
+		{Name: "health", Route: "/health", Ratio: 0},
+		{Name: "payments-post", Method: "POST", Route: "/api/payment", Ratio: 1},
+		{Name: "api", Route: "/api/*", Ratio: 1},
+		{Name: "save", SpanName: "save-*", Ratio: 1},
+	}, 1)
+
+	tests := []struct {
+		name     string
+		span     string
+		attrs    []attribute.KeyValue
+		wantRule string // empty when no rule sampled the span
+		want     sdktrace.SamplingDecision
+	}{
+		{
+			name:     "first matching rule wins",
+			span:     "HTTP POST",
+			attrs:    []attribute.KeyValue{semconv.HTTPMethodKey.String("POST"), semconv.HTTPRouteKey.String("/api/payment")},
+			wantRule: "payments-post",
+			want:     sdktrace.RecordAndSample,
+		},
+		{
+			name:     "method mismatch falls through to the next rule",
+			span:     "HTTP GET",
+			attrs:    []attribute.KeyValue{semconv.HTTPMethodKey.String("GET"), semconv.HTTPRouteKey.String("/api/payment")},
+			wantRule: "api",
+			want:     sdktrace.RecordAndSample,
+		},
+		{
+			name:  "matching rule with ratio 0 drops",
+			span:  "HTTP GET",
+			attrs: []attribute.KeyValue{semconv.HTTPMethodKey.String("GET"), semconv.HTTPRouteKey.String("/health")},
+			want:  sdktrace.Drop,
+		},
+		{
+			name:     "target without query when no route is known",
+			span:     "HTTP GET",
+			attrs:    []attribute.KeyValue{semconv.HTTPMethodKey.String("GET"), semconv.HTTPTargetKey.String("/api/fraud?card_id=1")},
+			wantRule: "api",
+			want:     sdktrace.RecordAndSample,
+		},
+		{
+			name:     "earlier route rule before span name rule",
+			span:     "save-payment",
+			attrs:    []attribute.KeyValue{semconv.HTTPRouteKey.String("/api/save")},
+			wantRule: "api",
+			want:     sdktrace.RecordAndSample,
+		},
+		{
+			name:     "span name prefix",
+			span:     "save-payment",
+			wantRule: "save",
+			want:     sdktrace.RecordAndSample,
+		},
+		{
+			name: "no rule matches",
+			span: "calculate-score",
+			want: sdktrace.RecordAndSample,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := s.ShouldSample(sdktrace.SamplingParameters{
+				TraceID:    trace.TraceID{1},
+				Name:       tt.span,
+				Attributes: tt.attrs,
+			})
+			if res.Decision != tt.want {
+				t.Errorf("decision = %v, want %v", res.Decision, tt.want)
+			}
+
+			var rule string
+			for _, kv := range res.Attributes {
+				if kv.Key == SamplingRuleKey {
+					rule = kv.Value.AsString()
+				}
+			}
+			if rule != tt.wantRule {
+				t.Errorf("rule = %q, want %q", rule, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestParseSamplingRules(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []SamplingRule
+		wantErr bool
+	}{
+		{
+			in: "name=payments,route=/api/payment,ratio=1; method=GET,route=/api/fraud,ratio=0.05",
+			want: []SamplingRule{
+				{Name: "payments", Route: "/api/payment", Ratio: 1},
+				{Name: "rule-1", Method: "GET", Route: "/api/fraud", Ratio: 0.05},
+			},
+		},
+		{in: "span=save-*,ratio=0.5;", want: []SamplingRule{{Name: "rule-0", SpanName: "save-*", Ratio: 0.5}}},
+		{in: "route=/api/payment", wantErr: true},
+		{in: "route=/api/payment,ratio=2", wantErr: true},
+		{in: "path=/api,ratio=1", wantErr: true},
+		{in: "ratio", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSamplingRules(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSamplingRules() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSamplingRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSampler(t *testing.T) {
+	rules := []SamplingRule{{Name: "payments", Route: "/api/payment", Ratio: 1}}
+	ruleSampler := "RuleSampler{payments:1,fallback:TraceIDRatioBased{0.25}}"
+
+	tests := []struct {
+		name string
+		cfg  Config
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "default",
+			want: "ParentBased{root:AlwaysOnSampler,remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}",
+		},
+		{
+			name: "ratio from config",
+			cfg:  Config{Sampler: SamplerTraceIDRatio, SamplerArg: "0.25"},
+			want: "TraceIDRatioBased{0.25}",
+		},
+		{
+			name: "config rules replace the ratio",
+			cfg:  Config{Sampler: SamplerTraceIDRatio, SamplerArg: "0.25", SamplingRules: rules},
+			want: ruleSampler,
+		},
+		{
+			name: "environment rules default to parent based",
+			env: map[string]string{
+				"OTEL_TRACES_SAMPLER_RULES": "name=payments,route=/api/payment,ratio=1",
+				"OTEL_TRACES_SAMPLER_ARG":   "0.25",
+			},
+			want: "ParentBased{root:" + ruleSampler + ",remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}",
+		},
+		{
+			name: "environment sampler overrides config",
+			cfg:  Config{Sampler: SamplerTraceIDRatio, SamplerArg: "0.25", SamplingRules: rules},
+			env:  map[string]string{"OTEL_TRACES_SAMPLER": SamplerAlwaysOff},
+			want: "AlwaysOffSampler",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"OTEL_TRACES_SAMPLER", "OTEL_TRACES_SAMPLER_ARG", "OTEL_TRACES_SAMPLER_RULES"} {
+				t.Setenv(k, tt.env[k])
+			}
+			s, err := newSampler(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Description(); got != tt.want {
+				t.Errorf("sampler = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
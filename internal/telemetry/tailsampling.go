@@ -0,0 +1,444 @@
+package telemetry
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/asyncint64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tail sampling limits, used when neither the environment nor the config
+// set a value.
+const (
+	defaultTailMaxTraces        = 10000
+	defaultTailMaxSpansPerTrace = 1000
+)
+
+// Reasons recorded in the tailsampling.traces metric.
+const (
+	keepReasonError     = "error"
+	keepReasonLatency   = "latency"
+	keepReasonAttribute = "attribute"
+	keepReasonNone      = "none"
+)
+
+// TailSamplingConfig buffers the spans of each trace and only exports the
+// traces found interesting once they are complete.
+//
+// The decision is made per process: a trace here is the part of it recorded
+// by this process, and every service decides on its own part. A fast,
+// successful part is dropped even when another service keeps the rest of
+// the trace, so the exported trace may have gaps, and exemplars may point at
+// traces that were not exported.
+type TailSamplingConfig struct {
+	// DecisionWait is how long the spans of a trace are buffered, counted
+	// from the first one to end, before the trace is kept or dropped. Zero
+	// disables tail sampling.
+	DecisionWait time.Duration
+	// Latency keeps traces whose spans cover at least this long. Zero
+	// disables the check. Traces with an error status are always kept.
+	Latency time.Duration
+	// Attributes keeps traces with a span matching any of the predicates.
+	Attributes []AttributePredicate
+	// MaxTraces bounds the traces being buffered. When it is reached the
+	// oldest trace is decided early.
+	MaxTraces int
+	// MaxSpansPerTrace bounds the spans buffered for one trace. Later spans
+	// of that trace are dropped.
+	MaxSpansPerTrace int
+}
+
+// AttributePredicate matches spans with the attribute Key set to one of
+// Values, compared in their Emit form, or to any value when Values is empty.
+type AttributePredicate struct {
+	Key    attribute.Key
+	Values []string
+}
+
+func (p AttributePredicate) matches(s sdktrace.ReadOnlySpan) bool {
+	for _, kv := range s.Attributes() {
+		if kv.Key != p.Key {
+			continue
+		}
+		if len(p.Values) == 0 {
+			return true
+		}
+		for _, v := range p.Values {
+			if kv.Value.Emit() == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withEnv overrides c with OTEL_TAILSAMPLING_DECISION_WAIT, _LATENCY (Go
+// durations), _ATTRIBUTES, _MAX_TRACES and _MAX_SPANS_PER_TRACE, like the
+// other OTEL_* variables override Config, so that a decision wait of 0s
+// turns tail sampling off. Attributes are written as key=value|value,key,
+// where a key alone matches any value.
+func (c TailSamplingConfig) withEnv() (TailSamplingConfig, error) {
+	d, err := time.ParseDuration(envOr("OTEL_TAILSAMPLING_DECISION_WAIT", c.DecisionWait.String()))
+	if err != nil {
+		return c, fmt.Errorf("telemetry: OTEL_TAILSAMPLING_DECISION_WAIT: %w", err)
+	}
+	c.DecisionWait = d
+
+	if d, err = time.ParseDuration(envOr("OTEL_TAILSAMPLING_LATENCY", c.Latency.String())); err != nil {
+		return c, fmt.Errorf("telemetry: OTEL_TAILSAMPLING_LATENCY: %w", err)
+	}
+	c.Latency = d
+
+	if v := os.Getenv("OTEL_TAILSAMPLING_ATTRIBUTES"); v != "" {
+		c.Attributes = parseAttributePredicates(v)
+	}
+
+	if c.MaxTraces == 0 {
+		c.MaxTraces = defaultTailMaxTraces
+	}
+	n, err := strconv.Atoi(envOr("OTEL_TAILSAMPLING_MAX_TRACES", strconv.Itoa(c.MaxTraces)))
+	if err != nil {
+		return c, fmt.Errorf("telemetry: OTEL_TAILSAMPLING_MAX_TRACES: %w", err)
+	}
+	c.MaxTraces = n
+
+	if c.MaxSpansPerTrace == 0 {
+		c.MaxSpansPerTrace = defaultTailMaxSpansPerTrace
+	}
+	if n, err = strconv.Atoi(envOr("OTEL_TAILSAMPLING_MAX_SPANS_PER_TRACE", strconv.Itoa(c.MaxSpansPerTrace))); err != nil {
+		return c, fmt.Errorf("telemetry: OTEL_TAILSAMPLING_MAX_SPANS_PER_TRACE: %w", err)
+	}
+	c.MaxSpansPerTrace = n
+
+	if c.DecisionWait < 0 || c.Latency < 0 {
+		return c, errors.New("telemetry: tail sampling durations must not be negative")
+	}
+	if c.MaxTraces < 1 || c.MaxSpansPerTrace < 1 {
+		return c, errors.New("telemetry: tail sampling limits must be positive")
+	}
+	return c, nil
+}
+
+func parseAttributePredicates(s string) []AttributePredicate {
+	var preds []AttributePredicate
+	for _, item := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(item, "=")
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+
+		p := AttributePredicate{Key: attribute.Key(k)}
+		if ok {
+			for _, value := range strings.Split(v, "|") {
+				p.Values = append(p.Values, strings.TrimSpace(value))
+			}
+		}
+		preds = append(preds, p)
+	}
+	return preds
+}
+
+// keepReason returns why the spans of a trace should be kept, or
+// keepReasonNone.
+func (c TailSamplingConfig) keepReason(spans []sdktrace.ReadOnlySpan) string {
+	for _, s := range spans {
+		if s.Status().Code == codes.Error {
+			return keepReasonError
+		}
+	}
+
+	if c.Latency > 0 {
+		var start, end time.Time
+		for _, s := range spans {
+			if start.IsZero() || s.StartTime().Before(start) {
+				start = s.StartTime()
+			}
+			if s.EndTime().After(end) {
+				end = s.EndTime()
+			}
+		}
+		if end.Sub(start) >= c.Latency {
+			return keepReasonLatency
+		}
+	}
+
+	for _, p := range c.Attributes {
+		for _, s := range spans {
+			if p.matches(s) {
+				return keepReasonAttribute
+			}
+		}
+	}
+	return keepReasonNone
+}
+
+type pendingTrace struct {
+	id       trace.TraceID
+	deadline time.Time
+	spans    []sdktrace.ReadOnlySpan
+}
+
+// tailSampler is a span processor that holds ended spans back by trace ID
+// and passes the whole trace on to next once it is kept. Spans that end
+// after their trace was decided follow the decision, as long as it is still
+// remembered.
+//
+// Memory is bounded by MaxTraces * MaxSpansPerTrace spans.
+type tailSampler struct {
+	cfg  TailSamplingConfig
+	next []sdktrace.SpanProcessor
+
+	mu      sync.Mutex
+	pending map[trace.TraceID]*list.Element
+	order   *list.List // of *pendingTrace, oldest first
+	decided map[trace.TraceID]bool
+	recent  []trace.TraceID // ring of decided trace IDs
+	oldest  int
+	stopped bool
+
+	traces       syncint64.Counter
+	evicted      syncint64.Counter
+	droppedSpans syncint64.Counter
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+var _ sdktrace.SpanProcessor = (*tailSampler)(nil)
+
+func newTailSampler(cfg TailSamplingConfig, mp metric.MeterProvider, next []sdktrace.SpanProcessor) (*tailSampler, error) {
+	t := &tailSampler{
+		cfg:     cfg,
+		next:    next,
+		pending: make(map[trace.TraceID]*list.Element),
+		order:   list.New(),
+		decided: make(map[trace.TraceID]bool),
+		recent:  make([]trace.TraceID, 0, cfg.MaxTraces),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	meter := mp.Meter("opentelemetry/internal/telemetry")
+	var err error
+	if t.traces, err = meter.SyncInt64().Counter("tailsampling.traces",
+		instrument.WithDescription("Traces decided by the tail sampler, by decision and reason")); err != nil {
+		return nil, fmt.Errorf("telemetry: tail sampling metrics: %w", err)
+	}
+	if t.evicted, err = meter.SyncInt64().Counter("tailsampling.traces.evicted",
+		instrument.WithDescription("Traces decided before their decision wait because the buffer was full")); err != nil {
+		return nil, fmt.Errorf("telemetry: tail sampling metrics: %w", err)
+	}
+	if t.droppedSpans, err = meter.SyncInt64().Counter("tailsampling.spans.dropped",
+		instrument.WithDescription("Spans dropped because their trace had too many buffered spans")); err != nil {
+		return nil, fmt.Errorf("telemetry: tail sampling metrics: %w", err)
+	}
+	buffered, err := meter.AsyncInt64().Gauge("tailsampling.traces.buffered",
+		instrument.WithDescription("Traces waiting for a tail sampling decision"))
+	if err != nil {
+		return nil, fmt.Errorf("telemetry: tail sampling metrics: %w", err)
+	}
+	if err := meter.RegisterCallback([]instrument.Asynchronous{buffered}, t.observe(buffered)); err != nil {
+		return nil, fmt.Errorf("telemetry: tail sampling metrics: %w", err)
+	}
+
+	go t.run()
+	return t, nil
+}
+
+func (t *tailSampler) observe(g asyncint64.Gauge) func(context.Context) {
+	return func(ctx context.Context) {
+		t.mu.Lock()
+		n := t.order.Len()
+		t.mu.Unlock()
+		g.Observe(ctx, int64(n))
+	}
+}
+
+func (t *tailSampler) run() {
+	defer close(t.done)
+
+	interval := t.cfg.DecisionWait / 10
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-ticker.C:
+			t.decide(func(pt *pendingTrace) bool { return !pt.deadline.After(now) })
+		}
+	}
+}
+
+func (t *tailSampler) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, p := range t.next {
+		p.OnStart(parent, s)
+	}
+}
+
+func (t *tailSampler) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	id := s.SpanContext().TraceID()
+
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	if keep, ok := t.decided[id]; ok {
+		t.mu.Unlock()
+		if keep {
+			t.forward([]sdktrace.ReadOnlySpan{s})
+		}
+		return
+	}
+
+	var early []*pendingTrace
+	e, ok := t.pending[id]
+	if !ok {
+		if t.order.Len() >= t.cfg.MaxTraces {
+			early = append(early, t.remove(t.order.Front()))
+		}
+		e = t.order.PushBack(&pendingTrace{id: id, deadline: time.Now().Add(t.cfg.DecisionWait)})
+		t.pending[id] = e
+	}
+	pt := e.Value.(*pendingTrace)
+	full := len(pt.spans) >= t.cfg.MaxSpansPerTrace
+	if !full {
+		pt.spans = append(pt.spans, s)
+	}
+	kept := t.decideLocked(early)
+	t.mu.Unlock()
+
+	if full {
+		t.droppedSpans.Add(context.Background(), 1)
+	}
+	if len(early) > 0 {
+		t.evicted.Add(context.Background(), int64(len(early)))
+	}
+	t.forward(kept)
+}
+
+// decide makes the decision for every buffered trace matching due and
+// forwards the spans of the kept ones.
+func (t *tailSampler) decide(due func(*pendingTrace) bool) {
+	var ready []*pendingTrace
+
+	t.mu.Lock()
+	for e := t.order.Front(); e != nil; {
+		pt := e.Value.(*pendingTrace)
+		if !due(pt) {
+			break
+		}
+		next := e.Next()
+		ready = append(ready, t.remove(e))
+		e = next
+	}
+	kept := t.decideLocked(ready)
+	t.mu.Unlock()
+
+	t.forward(kept)
+}
+
+func (t *tailSampler) remove(e *list.Element) *pendingTrace {
+	pt := t.order.Remove(e).(*pendingTrace)
+	delete(t.pending, pt.id)
+	return pt
+}
+
+// decideLocked decides the given traces, remembers the decisions for late
+// spans and returns the spans to forward.
+func (t *tailSampler) decideLocked(traces []*pendingTrace) []sdktrace.ReadOnlySpan {
+	var kept []sdktrace.ReadOnlySpan
+	for _, pt := range traces {
+		reason := t.cfg.keepReason(pt.spans)
+		keep := reason != keepReasonNone
+		if keep {
+			kept = append(kept, pt.spans...)
+		}
+		t.remember(pt.id, keep)
+
+		decision := "dropped"
+		if keep {
+			decision = "kept"
+		}
+		t.traces.Add(context.Background(), 1,
+			attribute.String("decision", decision),
+			attribute.String("reason", reason),
+		)
+	}
+	return kept
+}
+
+func (t *tailSampler) remember(id trace.TraceID, keep bool) {
+	if len(t.recent) < cap(t.recent) {
+		t.recent = append(t.recent, id)
+	} else {
+		delete(t.decided, t.recent[t.oldest])
+		t.recent[t.oldest] = id
+		t.oldest = (t.oldest + 1) % len(t.recent)
+	}
+	t.decided[id] = keep
+}
+
+func (t *tailSampler) forward(spans []sdktrace.ReadOnlySpan) {
+	for _, s := range spans {
+		for _, p := range t.next {
+			p.OnEnd(s)
+		}
+	}
+}
+
+// Shutdown decides every buffered trace right away and stops the sampler.
+// It does not shut down the processors behind it, which belong to the
+// caller.
+func (t *tailSampler) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	t.decide(func(*pendingTrace) bool { return true })
+
+	t.mu.Lock()
+	t.stopped = true
+	t.mu.Unlock()
+	return nil
+}
+
+// ForceFlush decides every buffered trace right away and flushes the
+// processors behind the sampler.
+func (t *tailSampler) ForceFlush(ctx context.Context) error {
+	t.decide(func(*pendingTrace) bool { return true })
+
+	for _, p := range t.next {
+		if err := p.ForceFlush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric/nonrecording"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var testStart = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testSpan returns an ended, sampled span of trace tid that ran from
+// start to end, counted from testStart.
+func testSpan(tid, sid byte, start, end time.Duration, status codes.Code, attrs ...attribute.KeyValue) sdktrace.ReadOnlySpan {
+	return tracetest.SpanStub{
+		Name: "test",
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{tid},
+			SpanID:     trace.SpanID{tid, sid},
+			TraceFlags: trace.FlagsSampled,
+		}),
+		StartTime:  testStart.Add(start),
+		EndTime:    testStart.Add(end),
+		Status:     sdktrace.Status{Code: status},
+		Attributes: attrs,
+	}.Snapshot()
+}
+
+func TestKeepReason(t *testing.T) {
+	cfg := TailSamplingConfig{
+		Latency: 2 * time.Second,
+		Attributes: []AttributePredicate{
+			{Key: "tenant", Values: []string{"acme", "globex"}},
+			{Key: "debug"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		cfg   TailSamplingConfig
+		spans []sdktrace.ReadOnlySpan
+		want  string
+	}{
+		{
+			name:  "fast and successful",
+			cfg:   cfg,
+			spans: []sdktrace.ReadOnlySpan{testSpan(1, 1, 0, time.Second, codes.Ok)},
+			want:  keepReasonNone,
+		},
+		{
+			name: "error in any span",
+			cfg:  cfg,
+			spans: []sdktrace.ReadOnlySpan{
+				testSpan(1, 1, 0, time.Second, codes.Unset),
+				testSpan(1, 2, 0, time.Second, codes.Error),
+			},
+			want: keepReasonError,
+		},
+		{
+			name:  "error before latency",
+			cfg:   cfg,
+			spans: []sdktrace.ReadOnlySpan{testSpan(1, 1, 0, 5*time.Second, codes.Error)},
+			want:  keepReasonError,
+		},
+		{
+			name: "latency across spans",
+			cfg:  cfg,
+			spans: []sdktrace.ReadOnlySpan{
+				testSpan(1, 1, 0, time.Second, codes.Unset),
+				testSpan(1, 2, 1500*time.Millisecond, 2*time.Second, codes.Unset),
+			},
+			want: keepReasonLatency,
+		},
+		{
+			name:  "latency check disabled",
+			cfg:   TailSamplingConfig{},
+			spans: []sdktrace.ReadOnlySpan{testSpan(1, 1, 0, time.Hour, codes.Unset)},
+			want:  keepReasonNone,
+		},
+		{
+			name:  "attribute with listed value",
+			cfg:   cfg,
+			spans: []sdktrace.ReadOnlySpan{testSpan(1, 1, 0, time.Second, codes.Unset, attribute.String("tenant", "globex"))},
+			want:  keepReasonAttribute,
+		},
+		{
+			name:  "attribute with other value",
+			cfg:   cfg,
+			spans: []sdktrace.ReadOnlySpan{testSpan(1, 1, 0, time.Second, codes.Unset, attribute.String("tenant", "initech"))},
+			want:  keepReasonNone,
+		},
+		{
+			name:  "attribute with any value",
+			cfg:   cfg,
+			spans: []sdktrace.ReadOnlySpan{testSpan(1, 1, 0, time.Second, codes.Unset, attribute.Bool("debug", false))},
+			want:  keepReasonAttribute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.keepReason(tt.spans); got != tt.want {
+				t.Errorf("keepReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTailSamplerLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TailSamplingConfig
+		// spans end in this order; a failed one keeps its trace.
+		spans []sdktrace.ReadOnlySpan
+		// wantEarly are the spans forwarded before the decision wait,
+		// wantAll those forwarded once every trace is decided.
+		wantEarly, wantAll int
+	}{
+		{
+			name:    "within limits",
+			cfg:     TailSamplingConfig{MaxTraces: 2, MaxSpansPerTrace: 2},
+			spans:   []sdktrace.ReadOnlySpan{testSpan(1, 1, 0, 0, codes.Error), testSpan(2, 1, 0, 0, codes.Error)},
+			wantAll: 2,
+		},
+		{
+			name: "oldest trace evicted at MaxTraces",
+			cfg:  TailSamplingConfig{MaxTraces: 2, MaxSpansPerTrace: 2},
+			spans: []sdktrace.ReadOnlySpan{
+				testSpan(1, 1, 0, 0, codes.Error),
+				testSpan(1, 2, 0, 0, codes.Error),
+				testSpan(2, 1, 0, 0, codes.Error),
+				testSpan(3, 1, 0, 0, codes.Error),
+			},
+			wantEarly: 2,
+			wantAll:   4,
+		},
+		{
+			name: "spans past MaxSpansPerTrace dropped",
+			cfg:  TailSamplingConfig{MaxTraces: 2, MaxSpansPerTrace: 2},
+			spans: []sdktrace.ReadOnlySpan{
+				testSpan(1, 1, 0, 0, codes.Error),
+				testSpan(1, 2, 0, 0, codes.Error),
+				testSpan(1, 3, 0, 0, codes.Error),
+			},
+			wantAll: 2,
+		},
+		{
+			name: "late span of an evicted trace follows its decision",
+			cfg:  TailSamplingConfig{MaxTraces: 1, MaxSpansPerTrace: 2},
+			spans: []sdktrace.ReadOnlySpan{
+				testSpan(1, 1, 0, 0, codes.Error),
+				testSpan(2, 1, 0, 0, codes.Error),
+				testSpan(1, 2, 0, 0, codes.Unset),
+			},
+			wantEarly: 2,
+			wantAll:   3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.DecisionWait = time.Hour
+			rec := tracetest.NewSpanRecorder()
+			ts, err := newTailSampler(tt.cfg, nonrecording.NewNoopMeterProvider(), []sdktrace.SpanProcessor{rec})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, s := range tt.spans {
+				ts.OnEnd(s)
+			}
+			if got := len(rec.Ended()); got != tt.wantEarly {
+				t.Errorf("forwarded %d spans before the decision wait, want %d", got, tt.wantEarly)
+			}
+
+			if err := ts.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(rec.Ended()); got != tt.wantAll {
+				t.Errorf("forwarded %d spans in all, want %d", got, tt.wantAll)
+			}
+		})
+	}
+}
+
+func TestTailSamplingConfigWithEnv(t *testing.T) {
+	cfg := TailSamplingConfig{DecisionWait: 10 * time.Second, Latency: 2 * time.Second, MaxTraces: 5}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want TailSamplingConfig
+	}{
+		{
+			name: "config only",
+			want: TailSamplingConfig{DecisionWait: 10 * time.Second, Latency: 2 * time.Second, MaxTraces: 5, MaxSpansPerTrace: defaultTailMaxSpansPerTrace},
+		},
+		{
+			name: "environment turns it off",
+			env:  map[string]string{"OTEL_TAILSAMPLING_DECISION_WAIT": "0s"},
+			want: TailSamplingConfig{Latency: 2 * time.Second, MaxTraces: 5, MaxSpansPerTrace: defaultTailMaxSpansPerTrace},
+		},
+		{
+			name: "environment overrides",
+			env: map[string]string{
+				"OTEL_TAILSAMPLING_LATENCY":             "500ms",
+				"OTEL_TAILSAMPLING_MAX_TRACES":          "7",
+				"OTEL_TAILSAMPLING_MAX_SPANS_PER_TRACE": "3",
+			},
+			want: TailSamplingConfig{DecisionWait: 10 * time.Second, Latency: 500 * time.Millisecond, MaxTraces: 7, MaxSpansPerTrace: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := cfg.withEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.DecisionWait != tt.want.DecisionWait || got.Latency != tt.want.Latency ||
+				got.MaxTraces != tt.want.MaxTraces || got.MaxSpansPerTrace != tt.want.MaxSpansPerTrace {
+				t.Errorf("withEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Package telemetry initialises OpenTelemetry tracing and metrics the same
+// way for every binary in this repository.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"opentelemetry/internal/tracefile"
+)
+
+// Config describes the service being instrumented and where its spans go.
+type Config struct {
+	// ServiceName is required. OTEL_SERVICE_NAME overrides it.
+	ServiceName string
+	// ServiceVersion defaults to the version or VCS revision of the binary.
+	ServiceVersion string
+	// Environment sets deployment.environment when it is not given in
+	// OTEL_RESOURCE_ATTRIBUTES.
+	Environment string
+
+	// Exporter is the comma separated list of span exporters used when
+	// OTEL_TRACES_EXPORTER is unset. It defaults to ExporterJaegerAgent.
+	Exporter string
+	// Batch tunes the batch processor of each exporter, keyed by exporter
+	// name.
+	Batch map[string]BatchConfig
+	// Jaeger configures the jaeger-agent and jaeger-collector exporters.
+	Jaeger JaegerConfig
+	// OTLP configures the otlp-grpc and otlp-http exporters.
+	OTLP OTLPConfig
+	// File configures the file exporter. Empty fields are filled from the
+	// OTEL_EXPORTER_FILE_* variables.
+	File tracefile.Config
+
+	// Propagators is the comma separated list of context propagators used
+	// when OTEL_PROPAGATORS is unset. It defaults to tracecontext,baggage.
+	Propagators string
+	// BaggageAttributes lists the baggage members copied onto every span
+	// started in the service. OTEL_BAGGAGE_SPAN_ATTRIBUTES is used when it
+	// is empty.
+	BaggageAttributes []string
+
+	// Sampler and SamplerArg are used when OTEL_TRACES_SAMPLER and
+	// OTEL_TRACES_SAMPLER_ARG are unset. Sampler defaults to
+	// SamplerParentBasedAlwaysOn, or to SamplerParentBasedTraceIDRatio when
+	// there are sampling rules.
+	Sampler    string
+	SamplerArg string
+	// SamplingRules are applied, in order, by the ratio samplers when
+	// OTEL_TRACES_SAMPLER_RULES is unset.
+	SamplingRules []SamplingRule
+	// TailSampling holds spans back until their trace is complete and only
+	// exports the interesting ones, deciding in each process on its own. The
+	// OTEL_TAILSAMPLING_* variables override it; it is off unless a decision
+	// wait is set.
+	TailSampling TailSamplingConfig
+}
+
+// Telemetry holds everything Setup created. Shutdown flushes and stops all
+// of it and must be called before the process exits.
+type Telemetry struct {
+	TracerProvider *sdktrace.TracerProvider
+	Propagator     propagation.TextMapPropagator
+	MeterProvider  metric.MeterProvider
+	// Metrics serves the metrics of MeterProvider to Prometheus, collected
+	// on every scrape, in OpenMetrics with exemplars when the scraper asks
+	// for it. Counters are named with a _total suffix.
+	Metrics  http.Handler
+	Shutdown func(context.Context) error
+}
+
+// Setup builds the tracer provider, propagator and meter provider described
+// by cfg and installs them as the otel globals.
+func Setup(ctx context.Context, cfg Config) (*Telemetry, error) {
+	if cfg.ServiceName == "" {
+		return nil, errors.New("telemetry: service name is required")
+	}
+
+	res, err := newResource(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	prop, err := newPropagator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sampler, err := newSampler(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tailCfg, err := cfg.TailSampling.withEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	exps, err := newExporters(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var bsps []sdktrace.SpanProcessor
+	for _, exp := range exps {
+		bsps = append(bsps, sdktrace.NewBatchSpanProcessor(exp, cfg.Batch[exp.name].options()...))
+	}
+
+	metricRes := metricResource(res)
+	reg := prometheus.NewRegistry()
+	ctrl, err := newPrometheusController(metricRes, reg)
+	if err != nil {
+		for _, bsp := range bsps {
+			bsp.Shutdown(ctx)
+		}
+		return nil, err
+	}
+
+	// With tail sampling the batch processors only see the traces it keeps.
+	procs := bsps
+	var tail *tailSampler
+	if tailCfg.DecisionWait > 0 {
+		if tail, err = newTailSampler(tailCfg, ctrl, bsps); err != nil {
+			for _, bsp := range bsps {
+				bsp.Shutdown(ctx)
+			}
+			return nil, err
+		}
+		procs = []sdktrace.SpanProcessor{tail}
+	}
+	if bp := newBaggageProcessor(cfg.BaggageAttributes); bp != nil {
+		procs = append([]sdktrace.SpanProcessor{bp}, procs...)
+	}
+
+	// The SDK applies OTEL_TRACES_SAMPLER before these options, so the
+	// sampler from newSampler wins either way.
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	}
+	for _, p := range procs {
+		opts = append(opts, sdktrace.WithSpanProcessor(p))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+
+	// Histograms link their buckets to sampled traces, which Prometheus
+	// only keeps when it scrapes OpenMetrics.
+	ex := newExemplars(metricRes)
+	mp := exemplarMeterProvider{ctrl, ex}
+	metrics := newMetricsHandler(ex.Gatherer(reg))
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(prop)
+	global.SetMeterProvider(mp)
+
+	return &Telemetry{
+		TracerProvider: tp,
+		Propagator:     prop,
+		MeterProvider:  mp,
+		Metrics:        metrics,
+		Shutdown: func(ctx context.Context) error {
+			// The tracer provider stops at the first failing processor, so
+			// flush each one here to let every destination drain.
+			var errs multiError
+			if tail != nil {
+				if err := tail.Shutdown(ctx); err != nil {
+					errs = append(errs, fmt.Errorf("tail sampler: %w", err))
+				}
+			}
+			for _, bsp := range bsps {
+				if err := bsp.Shutdown(ctx); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			// A provider without processors fails to shut down in this SDK
+			// version, and has nothing to flush anyway.
+			if len(procs) > 0 {
+				if err := tp.Shutdown(ctx); err != nil {
+					errs = append(errs, fmt.Errorf("tracer provider: %w", err))
+				}
+			}
+			if err := ctrl.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("meter provider: %w", err))
+			}
+			if len(errs) > 0 {
+				return fmt.Errorf("telemetry: shutdown: %w", errs)
+			}
+			return nil
+		},
+	}, nil
+}
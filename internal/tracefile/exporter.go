@@ -0,0 +1,49 @@
+package tracefile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Exporter is a span exporter writing one OTLP-JSON line per span to a
+// rotating file.
+type Exporter struct {
+	w *Writer
+}
+
+var _ sdktrace.SpanExporter = (*Exporter)(nil)
+
+// NewExporter opens the trace file described by cfg.
+func NewExporter(cfg Config) (*Exporter, error) {
+	w, err := NewWriter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{w: w}, nil
+}
+
+// ExportSpans appends spans to the trace file.
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	for _, s := range spans {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, err := json.Marshal(FromSpan(s))
+		if err != nil {
+			return fmt.Errorf("tracefile: encoding span: %w", err)
+		}
+		if _, err := e.w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown closes the trace file.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return e.w.Close()
+}
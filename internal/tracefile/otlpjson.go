@@ -0,0 +1,250 @@
+// Package tracefile stores spans on disk as OTLP-JSON lines, one span per
+// line, in files that rotate by size and age.
+package tracefile
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The types below follow the OTLP/JSON encoding of
+// opentelemetry.proto.trace.v1: lowerCamelCase names, hex encoded IDs and
+// 64-bit integers as strings. Each line of a trace file is a TracesData
+// holding a single span.
+
+// TracesData is one line of a trace file.
+type TracesData struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+// ResourceSpans groups spans produced by one resource.
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+	SchemaURL  string       `json:"schemaUrl,omitempty"`
+}
+
+// Resource is the entity that produced the spans.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+// ScopeSpans groups spans produced by one instrumentation scope.
+type ScopeSpans struct {
+	Scope     Scope  `json:"scope"`
+	Spans     []Span `json:"spans"`
+	SchemaURL string `json:"schemaUrl,omitempty"`
+}
+
+// Scope is the instrumentation library that created the spans.
+type Scope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// Span is a single finished span.
+type Span struct {
+	TraceID                string     `json:"traceId"`
+	SpanID                 string     `json:"spanId"`
+	TraceState             string     `json:"traceState,omitempty"`
+	ParentSpanID           string     `json:"parentSpanId,omitempty"`
+	Name                   string     `json:"name"`
+	Kind                   int        `json:"kind,omitempty"`
+	StartTimeUnixNano      uint64     `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano        uint64     `json:"endTimeUnixNano,string"`
+	Attributes             []KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32     `json:"droppedAttributesCount,omitempty"`
+	Events                 []Event    `json:"events,omitempty"`
+	DroppedEventsCount     uint32     `json:"droppedEventsCount,omitempty"`
+	Links                  []Link     `json:"links,omitempty"`
+	DroppedLinksCount      uint32     `json:"droppedLinksCount,omitempty"`
+	Status                 Status     `json:"status"`
+}
+
+// Event is a timestamped annotation on a span.
+type Event struct {
+	TimeUnixNano           uint64     `json:"timeUnixNano,string"`
+	Name                   string     `json:"name"`
+	Attributes             []KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32     `json:"droppedAttributesCount,omitempty"`
+}
+
+// Link points from a span to another span.
+type Link struct {
+	TraceID                string     `json:"traceId"`
+	SpanID                 string     `json:"spanId"`
+	TraceState             string     `json:"traceState,omitempty"`
+	Attributes             []KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount uint32     `json:"droppedAttributesCount,omitempty"`
+}
+
+// OTLP status codes. They differ from the numbering of codes.Code.
+const (
+	StatusCodeUnset = 0
+	StatusCodeOK    = 1
+	StatusCodeError = 2
+)
+
+// Status is the outcome of a span.
+type Status struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+// KeyValue is a single attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue holds exactly one of its fields.
+type AnyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *int64      `json:"intValue,omitempty,string"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue `json:"arrayValue,omitempty"`
+}
+
+// ArrayValue is a list of values.
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+// FromSpan returns the trace file line for s.
+func FromSpan(s sdktrace.ReadOnlySpan) TracesData {
+	span := Span{
+		TraceID:                s.SpanContext().TraceID().String(),
+		SpanID:                 s.SpanContext().SpanID().String(),
+		TraceState:             s.SpanContext().TraceState().String(),
+		Name:                   s.Name(),
+		Kind:                   int(s.SpanKind()),
+		StartTimeUnixNano:      unixNano(s.StartTime()),
+		EndTimeUnixNano:        unixNano(s.EndTime()),
+		Attributes:             fromAttributes(s.Attributes()),
+		DroppedAttributesCount: uint32(s.DroppedAttributes()),
+		DroppedEventsCount:     uint32(s.DroppedEvents()),
+		DroppedLinksCount:      uint32(s.DroppedLinks()),
+		Status:                 fromStatus(s.Status()),
+	}
+	if s.Parent().HasSpanID() {
+		span.ParentSpanID = s.Parent().SpanID().String()
+	}
+	for _, e := range s.Events() {
+		span.Events = append(span.Events, Event{
+			TimeUnixNano:           unixNano(e.Time),
+			Name:                   e.Name,
+			Attributes:             fromAttributes(e.Attributes),
+			DroppedAttributesCount: uint32(e.DroppedAttributeCount),
+		})
+	}
+	for _, l := range s.Links() {
+		span.Links = append(span.Links, fromLink(l.SpanContext, l.Attributes, l.DroppedAttributeCount))
+	}
+
+	rs := ResourceSpans{
+		ScopeSpans: []ScopeSpans{{
+			Scope: Scope{
+				Name:    s.InstrumentationLibrary().Name,
+				Version: s.InstrumentationLibrary().Version,
+			},
+			Spans:     []Span{span},
+			SchemaURL: s.InstrumentationLibrary().SchemaURL,
+		}},
+	}
+	if res := s.Resource(); res != nil {
+		rs.Resource.Attributes = fromAttributes(res.Attributes())
+		rs.SchemaURL = res.SchemaURL()
+	}
+
+	return TracesData{ResourceSpans: []ResourceSpans{rs}}
+}
+
+func fromLink(sc trace.SpanContext, attrs []attribute.KeyValue, dropped int) Link {
+	return Link{
+		TraceID:                sc.TraceID().String(),
+		SpanID:                 sc.SpanID().String(),
+		TraceState:             sc.TraceState().String(),
+		Attributes:             fromAttributes(attrs),
+		DroppedAttributesCount: uint32(dropped),
+	}
+}
+
+func fromStatus(s sdktrace.Status) Status {
+	switch s.Code {
+	case codes.Error:
+		return Status{Code: StatusCodeError, Message: s.Description}
+	case codes.Ok:
+		return Status{Code: StatusCodeOK}
+	default:
+		return Status{}
+	}
+}
+
+func fromAttributes(attrs []attribute.KeyValue) []KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	kvs := make([]KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		kvs = append(kvs, KeyValue{Key: string(kv.Key), Value: fromValue(kv.Value)})
+	}
+	return kvs
+}
+
+func fromValue(v attribute.Value) AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return AnyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := v.AsInt64()
+		return AnyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return AnyValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		var arr ArrayValue
+		for _, b := range v.AsBoolSlice() {
+			b := b
+			arr.Values = append(arr.Values, AnyValue{BoolValue: &b})
+		}
+		return AnyValue{ArrayValue: &arr}
+	case attribute.INT64SLICE:
+		var arr ArrayValue
+		for _, i := range v.AsInt64Slice() {
+			i := i
+			arr.Values = append(arr.Values, AnyValue{IntValue: &i})
+		}
+		return AnyValue{ArrayValue: &arr}
+	case attribute.FLOAT64SLICE:
+		var arr ArrayValue
+		for _, f := range v.AsFloat64Slice() {
+			f := f
+			arr.Values = append(arr.Values, AnyValue{DoubleValue: &f})
+		}
+		return AnyValue{ArrayValue: &arr}
+	case attribute.STRINGSLICE:
+		var arr ArrayValue
+		for _, s := range v.AsStringSlice() {
+			s := s
+			arr.Values = append(arr.Values, AnyValue{StringValue: &s})
+		}
+		return AnyValue{ArrayValue: &arr}
+	default:
+		s := v.Emit()
+		return AnyValue{StringValue: &s}
+	}
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
@@ -0,0 +1,488 @@
+package tracefile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReadFile reads every span stored in the file at path. Gzipped files are
+// decompressed transparently.
+func ReadFile(path string) (tracetest.SpanStubs, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("tracefile: %w", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("tracefile: %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	spans, err := Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("tracefile: %s: %w", path, err)
+	}
+	return spans, nil
+}
+
+// Read decodes a stream of JSON documents, each either an OTLP-JSON
+// TracesData as written by Exporter or a span as written by the stdouttrace
+// exporter, pretty printed or not.
+func Read(r io.Reader) (tracetest.SpanStubs, error) {
+	dec := json.NewDecoder(r)
+
+	var spans tracetest.SpanStubs
+	for {
+		var doc map[string]json.RawMessage
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			return spans, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case doc["resourceSpans"] != nil:
+			var td TracesData
+			if err := remarshal(doc, &td); err != nil {
+				return nil, err
+			}
+			stubs, err := td.spanStubs()
+			if err != nil {
+				return nil, err
+			}
+			spans = append(spans, stubs...)
+		case doc["SpanContext"] != nil:
+			var s stdoutSpan
+			if err := remarshal(doc, &s); err != nil {
+				return nil, err
+			}
+			stub, err := s.spanStub()
+			if err != nil {
+				return nil, err
+			}
+			spans = append(spans, stub)
+		default:
+			return nil, errors.New("unrecognised JSON document, want OTLP-JSON or stdouttrace output")
+		}
+	}
+}
+
+func remarshal(doc map[string]json.RawMessage, v interface{}) error {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (td TracesData) spanStubs() (tracetest.SpanStubs, error) {
+	var stubs tracetest.SpanStubs
+	for _, rs := range td.ResourceSpans {
+		attrs, err := toAttributes(rs.Resource.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		res := resource.NewWithAttributes(rs.SchemaURL, attrs...)
+
+		for _, ss := range rs.ScopeSpans {
+			lib := instrumentation.Library{
+				Name:      ss.Scope.Name,
+				Version:   ss.Scope.Version,
+				SchemaURL: ss.SchemaURL,
+			}
+			for _, s := range ss.Spans {
+				stub, err := s.spanStub()
+				if err != nil {
+					return nil, fmt.Errorf("span %s: %w", s.SpanID, err)
+				}
+				stub.Resource = res
+				stub.InstrumentationLibrary = lib
+				stubs = append(stubs, stub)
+			}
+		}
+	}
+	return stubs, nil
+}
+
+func (s Span) spanStub() (tracetest.SpanStub, error) {
+	sc, err := spanContext(s.TraceID, s.SpanID, s.TraceState)
+	if err != nil {
+		return tracetest.SpanStub{}, err
+	}
+
+	stub := tracetest.SpanStub{
+		Name:              s.Name,
+		SpanContext:       sc,
+		SpanKind:          trace.SpanKind(s.Kind),
+		StartTime:         fromUnixNano(s.StartTimeUnixNano),
+		EndTime:           fromUnixNano(s.EndTimeUnixNano),
+		DroppedAttributes: int(s.DroppedAttributesCount),
+		DroppedEvents:     int(s.DroppedEventsCount),
+		DroppedLinks:      int(s.DroppedLinksCount),
+		Status:            toStatus(s.Status),
+	}
+	if s.ParentSpanID != "" {
+		parent, err := trace.SpanIDFromHex(s.ParentSpanID)
+		if err != nil {
+			return stub, fmt.Errorf("parent span id: %w", err)
+		}
+		stub.Parent = sc.WithSpanID(parent)
+	}
+	if stub.Attributes, err = toAttributes(s.Attributes); err != nil {
+		return stub, err
+	}
+	for _, e := range s.Events {
+		attrs, err := toAttributes(e.Attributes)
+		if err != nil {
+			return stub, err
+		}
+		stub.Events = append(stub.Events, sdktrace.Event{
+			Name:                  e.Name,
+			Attributes:            attrs,
+			DroppedAttributeCount: int(e.DroppedAttributesCount),
+			Time:                  fromUnixNano(e.TimeUnixNano),
+		})
+	}
+	for _, l := range s.Links {
+		lsc, err := spanContext(l.TraceID, l.SpanID, l.TraceState)
+		if err != nil {
+			return stub, fmt.Errorf("link: %w", err)
+		}
+		attrs, err := toAttributes(l.Attributes)
+		if err != nil {
+			return stub, err
+		}
+		stub.Links = append(stub.Links, sdktrace.Link{
+			SpanContext:           lsc,
+			Attributes:            attrs,
+			DroppedAttributeCount: int(l.DroppedAttributesCount),
+		})
+	}
+	return stub, nil
+}
+
+// spanContext builds a sampled span context. OTLP does not carry trace
+// flags, but every span that reached a file was sampled.
+func spanContext(traceID, spanID, traceState string) (trace.SpanContext, error) {
+	tid, err := trace.TraceIDFromHex(traceID)
+	if err != nil {
+		return trace.SpanContext{}, fmt.Errorf("trace id: %w", err)
+	}
+	sid, err := trace.SpanIDFromHex(spanID)
+	if err != nil {
+		return trace.SpanContext{}, fmt.Errorf("span id: %w", err)
+	}
+	ts, err := trace.ParseTraceState(traceState)
+	if err != nil {
+		return trace.SpanContext{}, fmt.Errorf("trace state: %w", err)
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+		TraceState: ts,
+	}), nil
+}
+
+func toStatus(s Status) sdktrace.Status {
+	switch s.Code {
+	case StatusCodeError:
+		return sdktrace.Status{Code: codes.Error, Description: s.Message}
+	case StatusCodeOK:
+		return sdktrace.Status{Code: codes.Ok}
+	default:
+		return sdktrace.Status{}
+	}
+}
+
+func toAttributes(kvs []KeyValue) ([]attribute.KeyValue, error) {
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		v, err := kv.Value.value()
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", kv.Key, err)
+		}
+		attrs = append(attrs, attribute.KeyValue{Key: attribute.Key(kv.Key), Value: v})
+	}
+	return attrs, nil
+}
+
+func (v AnyValue) value() (attribute.Value, error) {
+	switch {
+	case v.StringValue != nil:
+		return attribute.StringValue(*v.StringValue), nil
+	case v.BoolValue != nil:
+		return attribute.BoolValue(*v.BoolValue), nil
+	case v.IntValue != nil:
+		return attribute.Int64Value(*v.IntValue), nil
+	case v.DoubleValue != nil:
+		return attribute.Float64Value(*v.DoubleValue), nil
+	case v.ArrayValue != nil:
+		return v.ArrayValue.value()
+	default:
+		return attribute.Value{}, errors.New("empty value")
+	}
+}
+
+// value converts the array to a typed slice. attribute values cannot mix
+// types, so the first element decides the slice type.
+func (a ArrayValue) value() (attribute.Value, error) {
+	if len(a.Values) == 0 {
+		return attribute.StringSliceValue(nil), nil
+	}
+
+	first := a.Values[0]
+	switch {
+	case first.StringValue != nil:
+		s := make([]string, 0, len(a.Values))
+		for _, v := range a.Values {
+			if v.StringValue == nil {
+				return attribute.Value{}, errors.New("mixed array value")
+			}
+			s = append(s, *v.StringValue)
+		}
+		return attribute.StringSliceValue(s), nil
+	case first.BoolValue != nil:
+		s := make([]bool, 0, len(a.Values))
+		for _, v := range a.Values {
+			if v.BoolValue == nil {
+				return attribute.Value{}, errors.New("mixed array value")
+			}
+			s = append(s, *v.BoolValue)
+		}
+		return attribute.BoolSliceValue(s), nil
+	case first.IntValue != nil:
+		s := make([]int64, 0, len(a.Values))
+		for _, v := range a.Values {
+			if v.IntValue == nil {
+				return attribute.Value{}, errors.New("mixed array value")
+			}
+			s = append(s, *v.IntValue)
+		}
+		return attribute.Int64SliceValue(s), nil
+	case first.DoubleValue != nil:
+		s := make([]float64, 0, len(a.Values))
+		for _, v := range a.Values {
+			if v.DoubleValue == nil {
+				return attribute.Value{}, errors.New("mixed array value")
+			}
+			s = append(s, *v.DoubleValue)
+		}
+		return attribute.Float64SliceValue(s), nil
+	default:
+		return attribute.Value{}, errors.New("unsupported array value")
+	}
+}
+
+func fromUnixNano(n uint64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(n))
+}
+
+// stdoutSpan mirrors the JSON written by the stdouttrace exporter, which
+// encodes tracetest.SpanStub with the SDK types' own JSON marshalling.
+type stdoutSpan struct {
+	Name        string
+	SpanContext stdoutSpanContext
+	Parent      stdoutSpanContext
+	SpanKind    int
+	StartTime   time.Time
+	EndTime     time.Time
+	Attributes  []stdoutKeyValue
+	Events      []struct {
+		Name                  string
+		Attributes            []stdoutKeyValue
+		DroppedAttributeCount int
+		Time                  time.Time
+	}
+	Links []struct {
+		SpanContext           stdoutSpanContext
+		Attributes            []stdoutKeyValue
+		DroppedAttributeCount int
+	}
+	Status struct {
+		Code        codes.Code
+		Description string
+	}
+	DroppedAttributes      int
+	DroppedEvents          int
+	DroppedLinks           int
+	ChildSpanCount         int
+	Resource               []stdoutKeyValue
+	InstrumentationLibrary instrumentation.Library
+}
+
+type stdoutSpanContext struct {
+	TraceID    string
+	SpanID     string
+	TraceFlags string
+	TraceState string
+	Remote     bool
+}
+
+type stdoutKeyValue struct {
+	Key   string
+	Value struct {
+		Type  string
+		Value json.RawMessage
+	}
+}
+
+func (s stdoutSpan) spanStub() (tracetest.SpanStub, error) {
+	sc, err := s.SpanContext.spanContext()
+	if err != nil {
+		return tracetest.SpanStub{}, err
+	}
+
+	stub := tracetest.SpanStub{
+		Name:                   s.Name,
+		SpanContext:            sc,
+		SpanKind:               trace.SpanKind(s.SpanKind),
+		StartTime:              s.StartTime,
+		EndTime:                s.EndTime,
+		Status:                 sdktrace.Status{Code: s.Status.Code, Description: s.Status.Description},
+		DroppedAttributes:      s.DroppedAttributes,
+		DroppedEvents:          s.DroppedEvents,
+		DroppedLinks:           s.DroppedLinks,
+		ChildSpanCount:         s.ChildSpanCount,
+		InstrumentationLibrary: s.InstrumentationLibrary,
+	}
+	if s.Parent.SpanID != "" && strings.Trim(s.Parent.SpanID, "0") != "" {
+		if stub.Parent, err = s.Parent.spanContext(); err != nil {
+			return stub, fmt.Errorf("parent: %w", err)
+		}
+	}
+	if stub.Attributes, err = toStdoutAttributes(s.Attributes); err != nil {
+		return stub, err
+	}
+	res, err := toStdoutAttributes(s.Resource)
+	if err != nil {
+		return stub, err
+	}
+	stub.Resource = resource.NewWithAttributes(s.InstrumentationLibrary.SchemaURL, res...)
+
+	for _, e := range s.Events {
+		attrs, err := toStdoutAttributes(e.Attributes)
+		if err != nil {
+			return stub, err
+		}
+		stub.Events = append(stub.Events, sdktrace.Event{
+			Name:                  e.Name,
+			Attributes:            attrs,
+			DroppedAttributeCount: e.DroppedAttributeCount,
+			Time:                  e.Time,
+		})
+	}
+	for _, l := range s.Links {
+		lsc, err := l.SpanContext.spanContext()
+		if err != nil {
+			return stub, fmt.Errorf("link: %w", err)
+		}
+		attrs, err := toStdoutAttributes(l.Attributes)
+		if err != nil {
+			return stub, err
+		}
+		stub.Links = append(stub.Links, sdktrace.Link{
+			SpanContext:           lsc,
+			Attributes:            attrs,
+			DroppedAttributeCount: l.DroppedAttributeCount,
+		})
+	}
+	return stub, nil
+}
+
+func (c stdoutSpanContext) spanContext() (trace.SpanContext, error) {
+	sc, err := spanContext(c.TraceID, c.SpanID, c.TraceState)
+	if err != nil {
+		return sc, err
+	}
+	if c.TraceFlags == "00" {
+		sc = sc.WithTraceFlags(0)
+	}
+	return sc.WithRemote(c.Remote), nil
+}
+
+func toStdoutAttributes(kvs []stdoutKeyValue) ([]attribute.KeyValue, error) {
+	if len(kvs) == 0 {
+		return nil, nil
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		v, err := stdoutValue(kv.Value.Type, kv.Value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %w", kv.Key, err)
+		}
+		attrs = append(attrs, attribute.KeyValue{Key: attribute.Key(kv.Key), Value: v})
+	}
+	return attrs, nil
+}
+
+// stdoutValue decodes an attribute.Value from its {"Type","Value"} JSON.
+func stdoutValue(typ string, raw json.RawMessage) (attribute.Value, error) {
+	switch typ {
+	case "BOOL":
+		var b bool
+		err := json.Unmarshal(raw, &b)
+		return attribute.BoolValue(b), err
+	case "INT64":
+		var i int64
+		err := json.Unmarshal(raw, &i)
+		return attribute.Int64Value(i), err
+	case "FLOAT64":
+		var f float64
+		err := json.Unmarshal(raw, &f)
+		return attribute.Float64Value(f), err
+	case "STRING":
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return attribute.StringValue(s), err
+	case "BOOLSLICE":
+		var s []bool
+		err := json.Unmarshal(raw, &s)
+		return attribute.BoolSliceValue(s), err
+	case "INT64SLICE":
+		var s []int64
+		err := json.Unmarshal(raw, &s)
+		return attribute.Int64SliceValue(s), err
+	case "FLOAT64SLICE":
+		var s []float64
+		err := json.Unmarshal(raw, &s)
+		return attribute.Float64SliceValue(s), err
+	case "STRINGSLICE":
+		var s []string
+		err := json.Unmarshal(raw, &s)
+		return attribute.StringSliceValue(s), err
+	default:
+		return attribute.Value{}, fmt.Errorf("unknown attribute type %q", typ)
+	}
+}
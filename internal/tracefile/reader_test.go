@@ -0,0 +1,221 @@
+package tracefile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testStub returns a span using every field both formats carry.
+func testStub() tracetest.SpanStub {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 123456789, time.UTC)
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		TraceFlags: trace.FlagsSampled,
+	})
+	link := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0xff, 1},
+		SpanID:     trace.SpanID{0xff, 2},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	return tracetest.SpanStub{
+		Name:        "process-payment",
+		SpanContext: sc,
+		Parent:      sc.WithSpanID(trace.SpanID{8, 7, 6, 5, 4, 3, 2, 1}),
+		SpanKind:    trace.SpanKindServer,
+		StartTime:   start,
+		EndTime:     start.Add(1500 * time.Millisecond),
+		Attributes: []attribute.KeyValue{
+			attribute.String("card_id", "1234"),
+			attribute.Int64("http.status_code", 500),
+			attribute.Float64("amount", 12.5),
+			attribute.Bool("retried", true),
+			attribute.StringSlice("tags", []string{"a", "b"}),
+			attribute.Int64Slice("sizes", []int64{1, 2}),
+		},
+		Events: []sdktrace.Event{{
+			Name:       "exception",
+			Attributes: []attribute.KeyValue{attribute.String("exception.message", "save timeout")},
+			Time:       start.Add(time.Second),
+		}},
+		Links: []sdktrace.Link{{
+			SpanContext: link,
+			Attributes:  []attribute.KeyValue{attribute.String("link", "producer")},
+		}},
+		Status:   sdktrace.Status{Code: codes.Error, Description: "save timeout"},
+		Resource: resource.NewSchemaless(attribute.String("service.name", "payments")),
+		InstrumentationLibrary: instrumentation.Library{
+			Name:    "payments",
+			Version: "v0.1.0",
+		},
+	}
+}
+
+func otlpJSON(t *testing.T, s tracetest.SpanStub) []byte {
+	t.Helper()
+
+	b, err := json.Marshal(FromSpan(s.Snapshot()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(b, '\n')
+}
+
+func stdoutJSON(t *testing.T, s tracetest.SpanStub, opts ...stdouttrace.Option) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	exp, err := stdouttrace.New(append(opts, stdouttrace.WithWriter(&buf))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{s.Snapshot()}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkSpan compares the fields of got and want that survive a trace file.
+func checkSpan(t *testing.T, got, want tracetest.SpanStub) {
+	t.Helper()
+
+	if got.Name != want.Name {
+		t.Errorf("Name = %q, want %q", got.Name, want.Name)
+	}
+	if !got.SpanContext.Equal(want.SpanContext) {
+		t.Errorf("SpanContext = %v, want %v", got.SpanContext, want.SpanContext)
+	}
+	if got.Parent.SpanID() != want.Parent.SpanID() {
+		t.Errorf("Parent = %v, want %v", got.Parent.SpanID(), want.Parent.SpanID())
+	}
+	if got.SpanKind != want.SpanKind {
+		t.Errorf("SpanKind = %v, want %v", got.SpanKind, want.SpanKind)
+	}
+	if !got.StartTime.Equal(want.StartTime) || !got.EndTime.Equal(want.EndTime) {
+		t.Errorf("times = %v..%v, want %v..%v", got.StartTime, got.EndTime, want.StartTime, want.EndTime)
+	}
+	if !reflect.DeepEqual(got.Attributes, want.Attributes) {
+		t.Errorf("Attributes = %v, want %v", got.Attributes, want.Attributes)
+	}
+	if len(got.Events) != len(want.Events) {
+		t.Fatalf("Events = %v, want %v", got.Events, want.Events)
+	}
+	for i, e := range got.Events {
+		w := want.Events[i]
+		if e.Name != w.Name || !e.Time.Equal(w.Time) || !reflect.DeepEqual(e.Attributes, w.Attributes) {
+			t.Errorf("Events[%d] = %v, want %v", i, e, w)
+		}
+	}
+	if len(got.Links) != len(want.Links) {
+		t.Fatalf("Links = %v, want %v", got.Links, want.Links)
+	}
+	for i, l := range got.Links {
+		w := want.Links[i]
+		if !l.SpanContext.Equal(w.SpanContext) || !reflect.DeepEqual(l.Attributes, w.Attributes) {
+			t.Errorf("Links[%d] = %v, want %v", i, l, w)
+		}
+	}
+	if got.Status != want.Status {
+		t.Errorf("Status = %v, want %v", got.Status, want.Status)
+	}
+	if !got.Resource.Equal(want.Resource) {
+		t.Errorf("Resource = %v, want %v", got.Resource, want.Resource)
+	}
+	if got.InstrumentationLibrary.Name != want.InstrumentationLibrary.Name ||
+		got.InstrumentationLibrary.Version != want.InstrumentationLibrary.Version {
+		t.Errorf("InstrumentationLibrary = %v, want %v", got.InstrumentationLibrary, want.InstrumentationLibrary)
+	}
+}
+
+func TestRead(t *testing.T) {
+	want := testStub()
+
+	tests := []struct {
+		name  string
+		input []byte
+		spans int
+	}{
+		{name: "otlp-json", input: otlpJSON(t, want), spans: 1},
+		{name: "stdouttrace", input: stdoutJSON(t, want), spans: 1},
+		{name: "stdouttrace pretty printed", input: stdoutJSON(t, want, stdouttrace.WithPrettyPrint()), spans: 1},
+		{
+			name:  "mixed",
+			input: bytes.Join([][]byte{otlpJSON(t, want), stdoutJSON(t, want, stdouttrace.WithPrettyPrint()), otlpJSON(t, want)}, nil),
+			spans: 3,
+		},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, err := Read(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(spans) != tt.spans {
+				t.Fatalf("read %d spans, want %d", len(spans), tt.spans)
+			}
+			for _, s := range spans {
+				checkSpan(t, s, want)
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "not json", input: "spans\n"},
+		{name: "unknown document", input: `{"name":"x"}`},
+		{name: "bad trace id", input: `{"resourceSpans":[{"scopeSpans":[{"spans":[{"traceId":"xyz","spanId":"0102030405060708"}]}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader([]byte(tt.input))); err == nil {
+				t.Error("Read() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestReadFileGzipped(t *testing.T) {
+	want := testStub()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(otlpJSON(t, want))
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "traces-2022-06-01T12-00-00.000.json.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	spans, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("read %d spans, want 1", len(spans))
+	}
+	checkSpan(t, spans[0], want)
+}
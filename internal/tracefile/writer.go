@@ -0,0 +1,294 @@
+package tracefile
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// backupTimeFormat is embedded in rotated file names. It sorts
+// lexicographically in time order.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Config controls where a Writer writes and when it rotates.
+type Config struct {
+	// Path of the active file. It is appended to, never truncated.
+	Path string
+	// MaxSize rotates the file before a write would take it past this many
+	// bytes. Zero disables size based rotation.
+	MaxSize int64
+	// MaxAge rotates the file once it has been written to for this long.
+	// Zero disables age based rotation.
+	MaxAge time.Duration
+	// MaxBackups is how many rotated files are kept. Zero keeps all of them.
+	MaxBackups int
+	// Compress gzips rotated files.
+	Compress bool
+}
+
+// Writer is an io.WriteCloser over a rotating file. Rotated files are
+// renamed to <name>-<timestamp><ext>, plus .gz when compressed.
+type Writer struct {
+	cfg Config
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	started time.Time
+	// closed is set by Close. f is also nil after a failed rotation, until
+	// a later write manages to open the file again.
+	closed bool
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter opens cfg.Path for appending, creating it if needed.
+func NewWriter(cfg Config) (*Writer, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("tracefile: path is required")
+	}
+	if cfg.MaxSize < 0 || cfg.MaxAge < 0 || cfg.MaxBackups < 0 {
+		return nil, errors.New("tracefile: rotation limits must not be negative")
+	}
+
+	w := &Writer{cfg: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes p to the active file, rotating first if p would exceed
+// MaxSize or the file is older than MaxAge. Callers should pass whole lines
+// so that no line is split across files.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.f == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			if w.f == nil {
+				return 0, err
+			}
+			// The active file is still open, so p is written to it and
+			// rotation is tried again on the next write.
+			otel.Handle(err)
+		}
+	}
+
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the active file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+func (w *Writer) shouldRotate(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.cfg.MaxSize > 0 && w.size+n > w.cfg.MaxSize {
+		return true
+	}
+	return w.cfg.MaxAge > 0 && time.Since(w.started) >= w.cfg.MaxAge
+}
+
+func (w *Writer) open() error {
+	if dir := filepath.Dir(w.cfg.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("tracefile: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(w.cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("tracefile: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("tracefile: %w", err)
+	}
+
+	w.f = f
+	w.size = info.Size()
+	// The creation time of an existing file is unknown, so its age is
+	// counted from the last write.
+	w.started = time.Now()
+	if w.size > 0 {
+		w.started = info.ModTime()
+	}
+	return nil
+}
+
+// rotate moves the active file to a backup and opens a new one. If that
+// fails the active file is reopened, and when even that fails the next
+// write tries again. Compressing and pruning backups happen after the new
+// file is open and their errors are only reported.
+func (w *Writer) rotate() error {
+	err := w.f.Close()
+	w.f = nil
+	if err != nil {
+		return w.reopen(fmt.Errorf("tracefile: closing %s: %w", w.cfg.Path, err))
+	}
+
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.cfg.Path, backup); err != nil {
+		return w.reopen(fmt.Errorf("tracefile: rotating %s: %w", w.cfg.Path, err))
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.cfg.Compress {
+		if err := compress(backup); err != nil {
+			otel.Handle(err)
+		}
+	}
+	if err := w.prune(); err != nil {
+		otel.Handle(err)
+	}
+	return nil
+}
+
+// reopen opens the active file again after a failed rotation and returns
+// err.
+func (w *Writer) reopen(err error) error {
+	if oerr := w.open(); oerr != nil {
+		return fmt.Errorf("%w; reopening: %v", err, oerr)
+	}
+	return err
+}
+
+// backupName returns the name of a backup made at t. Names only have
+// millisecond precision, so when one is taken, by itself or compressed, t
+// is moved forward until a free one is found, keeping backups in order.
+func (w *Writer) backupName(t time.Time) string {
+	dir, base := filepath.Dir(w.cfg.Path), filepath.Base(w.cfg.Path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext)
+	for {
+		name := filepath.Join(dir, prefix+"-"+t.Format(backupTimeFormat)+ext)
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// Backups returns the rotated files of the trace file at path, oldest
+// first.
+func Backups(path string) ([]string, error) {
+	dir, base := filepath.Dir(path), filepath.Base(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("tracefile: %w", err)
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		stamp = strings.TrimPrefix(stamp, prefix)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (w *Writer) prune() error {
+	if w.cfg.MaxBackups == 0 {
+		return nil
+	}
+
+	backups, err := Backups(w.cfg.Path)
+	if err != nil {
+		return err
+	}
+	for len(backups) > w.cfg.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("tracefile: removing old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// compress replaces path with a gzipped copy at path.gz.
+func compress(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("tracefile: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("tracefile: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		return fmt.Errorf("tracefile: compressing %s: %w", path, err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("tracefile: compressing %s: %w", path, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("tracefile: compressing %s: %w", path, err)
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,287 @@
+package tracefile
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAll returns the contents of path, gunzipped when it ends in .gz.
+func readAll(t *testing.T, path string) string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// backupContents returns the contents of the backups of path, oldest first.
+func backupContents(t *testing.T, path string) []string {
+	t.Helper()
+
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, b := range backups {
+		contents = append(contents, readAll(t, b))
+	}
+	return contents
+}
+
+func TestWriterSizeRotation(t *testing.T) {
+	lines := []string{"line-1\n", "line-2\n", "line-3\n", "line-4\n"}
+
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantActive  string
+		wantBackups []string
+	}{
+		{
+			name:       "no limits",
+			wantActive: "line-1\nline-2\nline-3\nline-4\n",
+		},
+		{
+			name:        "two lines per file",
+			cfg:         Config{MaxSize: 14},
+			wantActive:  "line-3\nline-4\n",
+			wantBackups: []string{"line-1\nline-2\n"},
+		},
+		{
+			name:        "one line per file",
+			cfg:         Config{MaxSize: 10},
+			wantActive:  "line-4\n",
+			wantBackups: []string{"line-1\n", "line-2\n", "line-3\n"},
+		},
+		{
+			name:        "oldest backups removed",
+			cfg:         Config{MaxSize: 10, MaxBackups: 2},
+			wantActive:  "line-4\n",
+			wantBackups: []string{"line-2\n", "line-3\n"},
+		},
+		{
+			name:        "backups compressed",
+			cfg:         Config{MaxSize: 10, MaxBackups: 1, Compress: true},
+			wantActive:  "line-4\n",
+			wantBackups: []string{"line-3\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Path = filepath.Join(t.TempDir(), "traces.json")
+			w, err := NewWriter(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			for _, l := range lines {
+				if _, err := w.Write([]byte(l)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := readAll(t, tt.cfg.Path); got != tt.wantActive {
+				t.Errorf("active file = %q, want %q", got, tt.wantActive)
+			}
+			if got := backupContents(t, tt.cfg.Path); !reflect.DeepEqual(got, tt.wantBackups) {
+				t.Errorf("backups = %q, want %q", got, tt.wantBackups)
+			}
+			if tt.cfg.Compress {
+				backups, _ := Backups(tt.cfg.Path)
+				for _, b := range backups {
+					if !strings.HasSuffix(b, ".gz") {
+						t.Errorf("backup %s is not compressed", b)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWriterBackupsInTheSameMillisecond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	w, err := NewWriter(Config{Path: path, MaxSize: 1, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		line := fmt.Sprintf("line-%02d\n", i)
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, line)
+	}
+
+	if got := append(backupContents(t, path), readAll(t, path)); !reflect.DeepEqual(got, want) {
+		t.Errorf("backups and active file = %q, want %q", got, want)
+	}
+}
+
+func TestWriterAgeRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		age         time.Duration
+		wantActive  string
+		wantBackups []string
+	}{
+		{
+			name:       "younger than MaxAge",
+			age:        time.Minute,
+			wantActive: "line-1\nline-2\n",
+		},
+		{
+			name:        "older than MaxAge",
+			age:         2 * time.Hour,
+			wantActive:  "line-2\n",
+			wantBackups: []string{"line-1\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "traces.json")
+			w, err := NewWriter(Config{Path: path, MaxAge: time.Hour})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			if _, err := w.Write([]byte("line-1\n")); err != nil {
+				t.Fatal(err)
+			}
+			w.started = time.Now().Add(-tt.age)
+			if _, err := w.Write([]byte("line-2\n")); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := readAll(t, path); got != tt.wantActive {
+				t.Errorf("active file = %q, want %q", got, tt.wantActive)
+			}
+			if got := backupContents(t, path); !reflect.DeepEqual(got, tt.wantBackups) {
+				t.Errorf("backups = %q, want %q", got, tt.wantBackups)
+			}
+		})
+	}
+}
+
+func TestWriterAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	if err := os.WriteFile(path, []byte("line-1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWriter(Config{Path: path, MaxSize: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for _, l := range []string{"line-2\n", "line-3\n"} {
+		if _, err := w.Write([]byte(l)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := readAll(t, path), "line-3\n"; got != want {
+		t.Errorf("active file = %q, want %q", got, want)
+	}
+	if got, want := backupContents(t, path), []string{"line-1\nline-2\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backups = %q, want %q", got, want)
+	}
+}
+
+func TestWriterKeepsWritingWhenRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	w, err := NewWriter(Config{Path: path, MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("line-1\n")); err != nil {
+		t.Fatal(err)
+	}
+	// With the active file gone the rotation cannot rename it.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"line-2\n", "line-3\n"} {
+		if _, err := w.Write([]byte(l)); err != nil {
+			t.Fatalf("Write(%q) = %v", l, err)
+		}
+	}
+
+	if got, want := readAll(t, path), "line-3\n"; got != want {
+		t.Errorf("active file = %q, want %q", got, want)
+	}
+	if got, want := backupContents(t, path), []string{"line-2\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("backups = %q, want %q", got, want)
+	}
+}
+
+func TestWriterReopensAfterFailedRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "traces")
+	path := filepath.Join(dir, "traces.json")
+	w, err := NewWriter(Config{Path: path, MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("line-1\n")); err != nil {
+		t.Fatal(err)
+	}
+	// A file in place of the directory makes both the rotation and the
+	// reopening fail.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("line-2\n")); err == nil {
+		t.Fatal("Write() succeeded with the directory gone")
+	}
+
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("line-3\n")); err != nil {
+		t.Fatalf("Write() after the directory came back = %v", err)
+	}
+	if got, want := readAll(t, path), "line-3\n"; got != want {
+		t.Errorf("active file = %q, want %q", got, want)
+	}
+
+	w.Close()
+	if _, err := w.Write([]byte("line-4\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after Close = %v, want os.ErrClosed", err)
+	}
+}
@@ -13,11 +13,8 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/jaeger"
-	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
-	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+
+	"opentelemetry/internal/telemetry"
 )
 
 // name is the Tracer name used to identify this instrumentation library.
@@ -77,20 +74,6 @@ func (a *App) Poll(ctx context.Context) (uint, error) {
 	return n, nil
 }
 
-// newResource returns a resource describing this application.
-func newResource() *resource.Resource {
-	r, _ := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String("fib"),
-			semconv.ServiceVersionKey.String("v0.1.0"),
-			attribute.String("environment", "demo"),
-		),
-	)
-	return r
-}
-
 // Write writes the n-th Fibonacci number back to the user.
 func (a *App) Write(ctx context.Context, n uint) {
 	_, span := otel.Tracer(name).Start(ctx, "Write")
@@ -139,38 +122,21 @@ func Fibonacci(n uint) (uint64, error) {
 func main() {
 	l := log.New(os.Stdout, "", 0)
 
-	// Write telemetry data to a file.
-	f, err := os.Create("traces.txt")
-	if err != nil {
-		l.Fatal(err)
-	}
-	defer f.Close()
-
-	exp, err := newExporter(f)
+	// Keep a local copy of every trace next to the one sent to Jaeger.
+	t, err := telemetry.Setup(context.Background(), telemetry.Config{
+		ServiceName: "fib",
+		Environment: "demo",
+		Exporter:    telemetry.ExporterFile + "," + telemetry.ExporterJaegerAgent,
+	})
 	if err != nil {
 		l.Fatal(err)
 	}
-
-	exp2, err := newJaegerExporter()
-	if err != nil {
-		l.Fatal(err)
-	}
-
-	fmt.Println("exp:", exp)
-	fmt.Println("exp2:", exp2)
-
-	tp := trace.NewTracerProvider(
-		trace.WithBatcher(exp2),
-		trace.WithResource(newResource()),
-	)
 	defer func() {
-		if err := tp.Shutdown(context.Background()); err != nil {
+		if err := t.Shutdown(context.Background()); err != nil {
 			l.Fatal(err)
 		}
 	}()
 
-	otel.SetTracerProvider(tp)
-
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt)
 
@@ -190,21 +156,3 @@ func main() {
 		}
 	}
 }
-
-func newJaegerExporter() (trace.SpanExporter, error) {
-	os.Setenv("OTEL_EXPORTER_JAEGER_ENDPOINT", "http://localhost:14268/api/traces")
-	os.Setenv("OTEL_EXPORTER_JAEGER_AGENT_PORT", "6831")
-
-	return jaeger.New(jaeger.WithAgentEndpoint())
-}
-
-// newExporter returns a console exporter.
-func newExporter(w io.Writer) (trace.SpanExporter, error) {
-	return stdouttrace.New(
-		stdouttrace.WithWriter(w),
-		// Use human-readable output.
-		stdouttrace.WithPrettyPrint(),
-		// Do not print timestamps for the demo.
-		stdouttrace.WithoutTimestamps(),
-	)
-}
@@ -1 +1 @@
-docker run -p 9090:9090 -v $(pwd)/prometheus.yml:/etc/prometheus/prometheus.yml  --name=prometheus prom/prometheus
+docker run -p 9090:9090 -v $(pwd)/prometheus.yml:/etc/prometheus/prometheus.yml  --name=prometheus prom/prometheus --config.file=/etc/prometheus/prometheus.yml --enable-feature=exemplar-storage
@@ -0,0 +1,162 @@
+// Command tracereplay re-exports spans archived by the file or stdout
+// exporters, so an incident's traces can be looked at again in a local
+// Jaeger or any other backend:
+//
+//	OTEL_TRACES_EXPORTER=jaeger-agent go run ./tracereplay -now -reid traces.txt
+//
+// The destination is chosen exactly like in the services, from
+// OTEL_TRACES_EXPORTER and the matching OTEL_EXPORTER_* variables.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"opentelemetry/internal/telemetry"
+	"opentelemetry/internal/tracefile"
+)
+
+func main() {
+	now := flag.Bool("now", false, "shift timestamps so that the latest span ends now")
+	reid := flag.Bool("reid", false, "give every trace a new random trace ID")
+	exporter := flag.String("exporter", telemetry.ExporterJaegerAgent, "span exporters to use when OTEL_TRACES_EXPORTER is unset")
+	batchSize := flag.Int("batch", 100, "number of spans sent per export call")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: tracereplay [flags] file...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || *batchSize < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	l := log.New(os.Stderr, "", 0)
+
+	var spans tracetest.SpanStubs
+	for _, path := range flag.Args() {
+		s, err := tracefile.ReadFile(path)
+		if err != nil {
+			l.Fatal(err)
+		}
+		spans = append(spans, s...)
+	}
+
+	if *now {
+		shiftToNow(spans, time.Now())
+	}
+	if *reid {
+		if err := reID(spans); err != nil {
+			l.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	exps, err := telemetry.NewExporters(ctx, telemetry.Config{Exporter: *exporter})
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	snapshots := spans.Snapshots()
+	failed := false
+	for _, exp := range exps {
+		for i := 0; i < len(snapshots); i += *batchSize {
+			end := i + *batchSize
+			if end > len(snapshots) {
+				end = len(snapshots)
+			}
+			if err := exp.ExportSpans(ctx, snapshots[i:end]); err != nil {
+				l.Println(err)
+				failed = true
+				break
+			}
+		}
+		if err := exp.Shutdown(ctx); err != nil {
+			l.Println(err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+
+	l.Printf("replayed %d spans from %d files", len(spans), flag.NArg())
+}
+
+// shiftToNow moves all timestamps by the same amount so that the latest span
+// ends at now, keeping the shape of each trace. Files written without
+// timestamps get now for every span.
+func shiftToNow(spans tracetest.SpanStubs, now time.Time) {
+	var latest time.Time
+	for _, s := range spans {
+		if s.EndTime.After(latest) {
+			latest = s.EndTime
+		}
+	}
+
+	shift := func(t time.Time) time.Time {
+		if t.IsZero() || latest.IsZero() {
+			return now
+		}
+		return t.Add(now.Sub(latest))
+	}
+
+	for i := range spans {
+		s := &spans[i]
+		s.StartTime = shift(s.StartTime)
+		s.EndTime = shift(s.EndTime)
+		for j := range s.Events {
+			s.Events[j].Time = shift(s.Events[j].Time)
+		}
+	}
+}
+
+// reID replaces every trace ID with a new random one, consistently across
+// span contexts, parents and links, so that a replay does not merge with the
+// original traces in the backend.
+func reID(spans tracetest.SpanStubs) error {
+	ids := make(map[trace.TraceID]trace.TraceID)
+	newID := func(old trace.TraceID) (trace.TraceID, error) {
+		if id, ok := ids[old]; ok {
+			return id, nil
+		}
+		var id trace.TraceID
+		if _, err := rand.Read(id[:]); err != nil {
+			return id, err
+		}
+		ids[old] = id
+		return id, nil
+	}
+
+	for i := range spans {
+		s := &spans[i]
+		id, err := newID(s.SpanContext.TraceID())
+		if err != nil {
+			return err
+		}
+		s.SpanContext = s.SpanContext.WithTraceID(id)
+		if s.Parent.IsValid() {
+			s.Parent = s.Parent.WithTraceID(id)
+		}
+	}
+
+	// Only links into the replayed traces are rewritten; anything else
+	// still points at the original trace.
+	for i := range spans {
+		for j, l := range spans[i].Links {
+			if id, ok := ids[l.SpanContext.TraceID()]; ok {
+				spans[i].Links[j].SpanContext = l.SpanContext.WithTraceID(id)
+			}
+		}
+	}
+	return nil
+}